@@ -3,552 +3,799 @@ package main
 import (
 	"bufio"
 	"bytes"
-	"compress/gzip"
-	"encoding/base64"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
-	"io/fs"
-	"log"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
-	"sort"
+	"runtime"
 	"strings"
-	"time"
 
-	"github.com/joho/godotenv"
-	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/genshinsim/gcsimdb/pkg/db"
+	"github.com/genshinsim/gcsimdb/pkg/ingest"
+	"github.com/genshinsim/gcsimdb/pkg/journal"
+	"github.com/genshinsim/gcsimdb/pkg/rerun"
+	"github.com/genshinsim/gcsimdb/pkg/sim"
+	"github.com/genshinsim/gcsimdb/pkg/viewer"
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
 )
 
-const ERIndex = 7
+const dbDir = "./db"
+const tmpDir = "./tmp"
+
+var inputfile = "dbinput.txt"
+var skipped = ""
+var skipDownload bool
+var force bool
+var upload bool
+var rerunOpts = rerun.Options{TmpDir: tmpDir}
+var gcsimPath string
+var gcsimArgs string
+var viewerURL = viewer.DefaultURL
+var serveAddr string
+var serveDir string
+var resume bool
+var journalPath string
+var planVersion string
+var batch bool
+var dryRun bool
+var charsPath string
+var fetcher = viewer.NewFetcher(viewer.DefaultURL)
+var csvFile string
+var conflictPolicy string
+var matchWeapons bool
+var rollback int
+
+// positional arguments left after the flags
+var args []string
+var csvColumns = ingest.DefaultCSVColumns
+var newChar db.Character
+var newCharAliases string
+
+type command struct {
+	name  string
+	usage string
+	flags func(fs *flag.FlagSet)
+	run   func() error
+}
+
+var commands = []command{
+	{
+		name:  "ingest",
+		usage: "add new and updated teams from the input file to ./db",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&inputfile, "i", inputfile, "file of submissions: url~author~description lines, or .yaml/.json/.csv with url, author and description")
+			viewerFlags(fs)
+			fetchFlags(fs)
+		},
+		run: runIngest,
+	},
+	{
+		name:  "import-csv",
+		usage: "add the teams in a spreadsheet export to ./db",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&csvFile, "i", "kurt-sheet.csv", "csv file with a header row")
+			fs.StringVar(&csvColumns.URL, "url-col", csvColumns.URL, "header of the column holding the share link")
+			fs.StringVar(&csvColumns.Author, "author-col", csvColumns.Author, "header of the column holding the author")
+			fs.StringVar(&csvColumns.Description, "desc-col", csvColumns.Description, "header of the column holding the description")
+			fs.StringVar(&csvColumns.Variant, "variant-col", csvColumns.Variant, "header of the column holding the team variant, if any")
+			viewerFlags(fs)
+			fetchFlags(fs)
+		},
+		run: runImportCSV,
+	},
+	{
+		name:  "rerun",
+		usage: "rerun stale teams with the latest gcsim and update their yaml",
+		flags: func(fs *flag.FlagSet) {
+			downloadFlags(fs)
+			rerunFlags(fs)
+		},
+		run: runRerun,
+	},
+	{
+		name:  "plan",
+		usage: "list the teams rerun would resimulate and why",
+		flags: func(fs *flag.FlagSet) {
+			downloadFlags(fs)
+			rerunFlags(fs)
+		},
+		run: runPlan,
+	},
+	{
+		name:  "upload",
+		usage: "upload results left in ./tmp by the last rerun to the viewer",
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&force, "f", false, "move rerun teams into the folder of their main dps")
+			viewerFlags(fs)
+		},
+		run: runUpload,
+	},
+	{
+		name:  "index",
+		usage: "upload the db index to the viewer",
+		flags: viewerFlags,
+		run:   runIndex,
+	},
+	{
+		name:  "history",
+		usage: "show how the dps of a team changed over time, or roll it back: history [-rollback n] <team>",
+		flags: func(fs *flag.FlagSet) {
+			fs.IntVar(&rollback, "rollback", 0, "put the config of this revision back; the team reruns with it next time")
+		},
+		run: runHistory,
+	},
+	{
+		name:  "validate",
+		usage: "check every file in ./db against the db schema",
+		run:   runValidate,
+	},
+	{
+		name:  "run",
+		usage: "ingest, rerun and (with -u) upload in one go",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&inputfile, "i", inputfile, "file of submissions (see ingest -h); empty to skip ingesting")
+			fs.BoolVar(&upload, "u", false, "upload to db")
+			viewerFlags(fs)
+			fetchFlags(fs)
+			downloadFlags(fs)
+			rerunFlags(fs)
+		},
+		run: runAll,
+	},
+	{
+		name:  "add-char",
+		usage: "add a character to the character registry",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&newChar.Key, "key", "", "name gcsim reports for the character, e.g. kukishinobu")
+			fs.StringVar(&newChar.Name, "name", "", "display name, also used as the db folder")
+			fs.StringVar(&newChar.Abbr, "abbr", "", "two letter abbreviation used in team file names")
+			fs.StringVar(&newChar.Element, "element", "", "element, e.g. electro")
+			fs.StringVar(&newChar.Weapon, "weapon", "", "weapon type, e.g. sword")
+			fs.StringVar(&newCharAliases, "aliases", "", "comma separated other names gcsim accepts in configs")
+		},
+		run: runAddChar,
+	},
+	{
+		name:  "serve-viewer",
+		usage: "serve a local stand-in for the viewer, for offline runs",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&serveAddr, "addr", "localhost:8381", "address to listen on")
+			fs.StringVar(&serveDir, "dir", "./viewer-data", "folder to keep uploaded results and the index in")
+		},
+		run: runServeViewer,
+	},
+}
+
+func viewerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&viewerURL, "viewer", viewerURL, "base url of the viewer, e.g. http://localhost:8381 for serve-viewer")
+}
+
+func fetchFlags(fs *flag.FlagSet) {
+	fs.StringVar(&conflictPolicy, "conflicts", ingest.PolicyReport, "what to do with several submissions of the same team: "+strings.Join(ingest.Policies, ", "))
+	fs.BoolVar(&matchWeapons, "match-weapons", false, "send submissions without a variant to the variant of their team with the same weapons, or a new one")
+	fs.DurationVar(&fetcher.Timeout, "fetch-timeout", fetcher.Timeout, "timeout for each request for a share link")
+	fs.IntVar(&fetcher.Retries, "fetch-retries", fetcher.Retries, "how many times to retry a failed request for a share link")
+	fs.DurationVar(&fetcher.Backoff, "fetch-backoff", fetcher.Backoff, "wait before the first retry, doubled for each one after")
+	fs.StringVar(&fetcher.CacheDir, "cache", "./viewer-cache", "folder to cache fetched results in; empty to always fetch")
+}
+
+func downloadFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&skipDownload, "d", false, "skip re-download executable?")
+	fs.StringVar(&gcsimPath, "gcsim", "./gcsim", "path to the gcsim executable; the latest build is downloaded there unless -d is set")
+	fs.StringVar(&gcsimArgs, "gcsim-args", "", "extra arguments passed to every gcsim run")
+	fs.StringVar(&planVersion, "version", "", "gcsim version to plan against instead of asking gcsim (plan and -dry-run only)")
+}
+
+func rerunFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&force, "f", false, "force rerun all")
+	fs.IntVar(&rerunOpts.Workers, "w", runtime.NumCPU(), "number of sims to run in parallel")
+	fs.BoolVar(&rerunOpts.KeepGoing, "k", false, "keep going when a sim or (in run) a submission fails")
+	fs.StringVar(&rerunOpts.ReportPath, "report", "failures.json", "where to write the failure report")
+	fs.StringVar(&rerunOpts.RegressionPath, "regressions", "regressions.json", "where to write the report of teams whose dps changed")
+	fs.Float64Var(&rerunOpts.RegressionZ, "regression-z", rerun.DefaultRegressionZ, "standard errors a dps change needs to be flagged")
+	fs.Float64Var(&rerunOpts.RegressionPct, "regression-pct", rerun.DefaultRegressionPct, "percent a dps change needs to be flagged")
+	fs.StringVar(&rerunOpts.StaleMode, "stale", rerun.StaleVersion, "which teams to rerun: 'version' (no hash, config edited or hash differs from gcsim) or 'empty' (only no hash or config edited)")
+	fs.BoolVar(&resume, "resume", false, "pick up where an interrupted run stopped")
+	fs.StringVar(&journalPath, "journal", "./run-journal.json", "where to record the progress of the run")
+}
 
 func main() {
-	var d bool
-	var force bool
-	flag.BoolVar(&d, "d", false, "skip re-download executable?")
-	flag.BoolVar(&force, "f", false, "force rerun all")
-	flag.Parse()
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == os.Args[1] {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Printf("Unknown command: %v\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
 
-	err := run(d, force)
+	fs := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+	fs.BoolVar(&batch, "yes", false, "never wait for 'Enter' (implied when stdin is not a terminal)")
+	fs.BoolVar(&batch, "batch", false, "alias for -yes")
+	fs.BoolVar(&dryRun, "dry-run", false, "report what would change without touching ./db, ./tmp or the viewer")
+	fs.StringVar(&charsPath, "chars", "./pkg/db/characters.yaml", "character registry to use; the built in one is used if it doesn't exist")
+	fs.Parse(os.Args[2:])
+	args = fs.Args()
+	batch = batch || !isTerminal(os.Stdin)
+
+	err := loadCharacters()
+	if err == nil {
+		err = cmd.run()
+	}
 
 	if err != nil {
 		fmt.Printf("Error encountered, ending script: %+v\n", err)
 	}
+	if skipped != "" {
+		fmt.Printf("\n%v\n", skipped)
+	}
+
+	pause()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Print("Usage: gcsimdb <command> [flags]\n\nCommands:\n")
+	width := 0
+	for _, c := range commands {
+		if len(c.name) > width {
+			width = len(c.name)
+		}
+	}
+	for _, c := range commands {
+		fmt.Printf("  %-*v  %v\n", width, c.name, c.usage)
+	}
+	fmt.Print("\nRun 'gcsimdb <command> -h' for the flags of a command.\n")
+}
 
+// readingFile reports progress while the db loads
+func readingFile(path string) {
+	fmt.Printf("\tReading file: %v at %v\n", filepath.Base(path), path)
+}
+
+// pause waits for the operator to press 'Enter' unless running in batch mode
+func pause() {
+	if batch {
+		return
+	}
 	fmt.Print("\nPress 'Enter' to continue...")
 	bufio.NewReader(os.Stdin).ReadBytes('\n')
 }
 
-func run(skipDownload bool, force bool) error {
+// isTerminal reports whether f is an interactive terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	//cron and containers usually hand us the null device, which is also a char device
+	if null, err := os.Stat(os.DevNull); err == nil && os.SameFile(info, null) {
+		return false
+	}
+	return true
+}
 
-	if !skipDownload {
-		//download nightly cmd line build
-		//https://github.com/genshinsim/gcsim/releases/download/nightly/gcsim.exe
-		err := download("./gcsim.exe", "https://github.com/genshinsim/gcsim/releases/latest/download/gcsim.exe")
-		if err != nil {
-			return errors.Wrap(err, "")
+func runIngest() error {
+	return ingestData()
+}
+
+// ingestData adds the submissions in inputfile to the db
+func ingestData() error {
+	_, err := ingestTouched()
+	return err
+}
+
+// ingestTouched ingests inputfile and returns the files it changed or, in a
+// dry run, would have changed
+func ingestTouched() ([]string, error) {
+	return ingestWith(func(in *ingest.Ingester) error {
+		return in.UpdateData(inputfile)
+	})
+}
+
+func runImportCSV() error {
+	_, err := ingestWith(func(in *ingest.Ingester) error {
+		return in.Add(ingest.ReadCSV(csvFile, csvColumns))
+	})
+	return err
+}
+
+// ingestWith runs add with an ingester for ./db and returns the files it touched
+func ingestWith(add func(in *ingest.Ingester) error) ([]string, error) {
+	client := viewer.NewClient(viewerURL)
+	fetcher.BaseURL = client.BaseURL
+	client.Fetcher = fetcher
+	in := ingest.Ingester{Dir: dbDir, Viewer: client, DryRun: dryRun, Policy: conflictPolicy, MatchWeapons: matchWeapons}
+	err := add(&in)
+	skipped += in.Summary()
+	return in.Touched(), err
+}
+
+// keepGoing drops the error of an ingest where only some submissions failed
+// if -k was given; the failures are still listed in the summary
+func keepGoing(err error) error {
+	var failed *ingest.FailedError
+	if rerunOpts.KeepGoing && errors.As(err, &failed) {
+		fmt.Printf("%v, carrying on\n", failed)
+		return nil
+	}
+	return err
+}
+
+// gcsimVersion asks gcsim for its version unless one was given with -version
+func gcsimVersion(runner sim.Runner) (string, error) {
+	if planVersion != "" {
+		//a made up version must never end up in the db
+		if !dryRun {
+			return "", errors.New("-version can only be used with plan or -dry-run")
 		}
+		return planVersion, nil
 	}
+	return runner.Version()
+}
 
-	//grab latest hash
-	hash, err := getVersion()
+func runRerun() error {
+	j, err := openJournal()
 	if err != nil {
 		return errors.Wrap(err, "")
 	}
-	// hash = strings.Trim(hash, "\n")
-	// log.Println(hash)
-
-	//loop through db folder; check hash
-	data, err := loadData("./db")
+	data, err := rerunAll(j)
 	if err != nil {
 		return errors.Wrap(err, "")
 	}
-
-	//process
-	err = process(data, hash, force)
+	if dryRun {
+		return nil
+	}
+	err = db.Save(data, false)
 	if err != nil {
 		return errors.Wrap(err, "")
 	}
+	return j.Remove()
+}
 
-	// for i := range data {
-	// 	sort.Slice(data[i].Team, func(k, j int) bool { return data[i].Team[k].Name < data[i].Team[j].Name })
-	// }
-
-	//store on cloudflare kv
-	err = uploadResults(data)
+// openJournal continues the journal of the last run when resuming, or starts a new one
+func openJournal() (*journal.Journal, error) {
+	if !resume {
+		return journal.New(journalPath), nil
+	}
+	j, err := journal.Open(journalPath)
 	if err != nil {
-		return errors.Wrap(err, "")
+		return nil, errors.Wrap(err, "nothing to resume")
 	}
+	//a fresh download could change the gcsim version halfway through
+	skipDownload = true
+	fmt.Printf("Resuming run started %v\n", j.Started.Format("2006-01-02 15:04"))
+	return j, nil
+}
 
-	err = uploadIndex(data)
+func runPlan() error {
+	//nothing a plan does should touch the db
+	dryRun = true
+	hash, err := gcsimVersion(sim.NewExecRunner(gcsimPath))
 	if err != nil {
 		return errors.Wrap(err, "")
 	}
-
-	err = saveYaml(data)
+	data, err := db.Load(dbDir, nil)
 	if err != nil {
 		return errors.Wrap(err, "")
 	}
 
+	opts := rerunOpts
+	opts.Force = force
+	if resume {
+		opts.Resume = true
+		opts.Journal, err = journal.Open(journalPath)
+		if err != nil {
+			return errors.Wrap(err, "nothing to resume")
+		}
+	}
+
+	rerun.PrintPlan(rerun.Plan(data, hash, opts), len(data), hash)
 	return nil
 }
 
-func download(path string, url string) error {
-	//remove if exists
-	if _, err := os.Stat(path); !os.IsNotExist(err) {
-		fmt.Printf("%v already exists, deleting...\n", path)
-		// path/to/whatever exists
-		os.RemoveAll(path)
-	}
-
-	fmt.Printf("Downloading: %v\n", url)
-	// Get the data
-	resp, err := http.Get(url)
+func runUpload() error {
+	data, err := db.Load(dbDir, readingFile)
 	if err != nil {
 		return errors.Wrap(err, "")
 	}
-	defer resp.Body.Close()
+	rerun.LoadTmpResults(data, tmpDir)
 
-	// Create the file
-	out, err := os.Create(path)
+	//store on cloudflare kv
+	client := viewer.NewClient(viewerURL)
+	client.DryRun = dryRun
+	err = client.UploadResults(data)
 	if err != nil {
 		return errors.Wrap(err, "")
 	}
-	defer out.Close()
-
-	// Write the body to file
-	_, err = io.Copy(out, resp.Body)
-	return errors.Wrap(err, "")
-}
-
-type pack struct {
-	Author      string `yaml:"author" json:"author"`
-	Config      string `yaml:"config" json:"config"`
-	Description string `yaml:"description" json:"description"`
-	//the following are machine generated fields
-	Hash      string  `yaml:"hash" json:"hash"`
-	Team      []char  `yaml:"team" json:"team"`
-	DPS       float64 `yaml:"dps" json:"dps"`
-	Mode      string  `yaml:"mode" json:"mode"`
-	Duration  float64 `yaml:"duration" json:"duration"`
-	NumTarget int     `yaml:"target_count" json:"target_count"`
-	ViewerKey string  `yaml:"viewer_key" json:"viewer_key"`
-	//unexported stuff
-	gzPath    string
-	filepath  string
-	filepath2 string
-	changed   bool
-}
-
-type char struct {
-	Name    string       `yaml:"name" json:"name"`
-	Con     int          `yaml:"con" json:"con"`
-	Weapon  string       `yaml:"weapon" json:"weapon"`
-	Refine  int          `yaml:"refine" json:"refine"`
-	ER      float64      `yaml:"er" json:"er"`
-	Talents TalentDetail `yaml:"talents" json:"talents"`
-}
-
-type result struct {
-	Duration FloatResult `json:"sim_duration"`
-	DPS      FloatResult `json:"dps"`
-	Targets  []struct {
-		Level int `json:"level"`
-	} `json:"target_details"`
-	Characters []struct {
-		Name   string `json:"name"`
-		Cons   int    `json:"cons"`
-		Weapon struct {
-			Name   string `json:"name"`
-			Refine int    `json:"refine"`
-		} `json:"weapon"`
-		Stats   []float64    `json:"stats"`
-		Talents TalentDetail `json:"talents"`
-	} `json:"char_details"`
-}
-
-type TalentDetail struct {
-	Attack int `json:"attack"`
-	Skill  int `json:"skill"`
-	Burst  int `json:"burst"`
-}
-
-type FloatResult struct {
-	Min  float64 `json:"min"`
-	Max  float64 `json:"max"`
-	Mean float64 `json:"mean"`
-	SD   float64 `json:"sd"`
-}
-
-func loadData(dir string) ([]pack, error) {
-	var data []pack
-
-	err := filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
-		if err != nil {
-			return errors.Wrap(err, "")
-		}
-		//do nothing if is directory
-		if info.IsDir() {
-			return nil
-		}
-		fmt.Printf("\tReading file: %v at %v\n", info.Name(), path)
-		file, err := os.ReadFile(path)
-		if err != nil {
-			return errors.Wrap(err, "")
-		}
-		var d pack
-		err = yaml.Unmarshal(file, &d)
-		if err != nil {
-			return errors.Wrap(err, "")
-		}
-		d.filepath = path
-
-		data = append(data, d)
 
+	if dryRun {
+		printMoves(data, force)
 		return nil
-	})
-
-	return data, err
-}
-
-var reIter = regexp.MustCompile(`iteration=(\d+)`)
-var reWorkers = regexp.MustCompile(`workers=(\d+)`)
-var reMode = regexp.MustCompile(`mode=(\w+)`)
-
-func process(data []pack, latest string, force bool) error {
-	//make a tmp folder if it doesn't exist
-	if _, err := os.Stat("./tmp"); !os.IsNotExist(err) {
-		fmt.Println("tmp folder already exists, deleting...")
-		// path/to/whatever exists
-		os.RemoveAll("./tmp/")
 	}
-	os.Mkdir("./tmp", 0755)
-
-	fmt.Println("Rerunning configs...")
-
-	for i := range data {
-		//compare hash vs current hash; if not the same rerun
-		if !force && data[i].Hash == latest {
-			fmt.Printf("\tSkipping %v\n", data[i].filepath)
-			continue
-		}
-		data[i].changed = true
-
-		//sort.Slice(data[i].Team, func(k, j int) bool { return data[i].Team[k].Name < data[i].Team[j].Name })
-
-		//fix the iterations
-		data[i].Config = reIter.ReplaceAllString(data[i].Config, "iteration=1000")
-		data[i].Config = reWorkers.ReplaceAllString(data[i].Config, "workers=30")
-		//re run sim
-		fmt.Printf("\tRerunning %v\n", data[i].filepath)
-		outPath := fmt.Sprintf("./tmp/%v", time.Now().Nanosecond())
-		err := runSim(data[i].Config, outPath)
-		if err != nil {
-			return errors.Wrap(err, "")
-		}
-		//read the json and populate
-		data[i].Hash = latest
-		jsonData, err := os.ReadFile(outPath + ".json")
-		if err != nil {
-			return errors.Wrap(err, "")
-		}
-		readResultJSON(jsonData, &data[i])
-
-		//find the mode
-		match := reMode.FindStringSubmatch(data[i].Config)
-		if match != nil {
-			data[i].Mode = match[1]
-		}
+	return db.Save(data, force)
+}
 
-		//overwrite yaml
-		out, err := yaml.Marshal(data[i])
+// printMoves reports the teams saving with relocate would move to another file
+func printMoves(data []db.Pack, relocate bool) {
+	for _, p := range data {
+		dest, err := db.Destination(p, relocate)
 		if err != nil {
-			return errors.Wrap(err, "")
+			fmt.Printf("\t[dry-run] can't place %v: %v\n", p.Path, err)
+		} else if filepath.Clean(dest) != filepath.Clean(p.Path) {
+			fmt.Printf("\t[dry-run] would move %v to %v\n", p.Path, dest)
 		}
-		os.Remove(data[i].filepath)
-		err = os.WriteFile(data[i].filepath, out, 0755)
-		if err != nil {
-			return errors.Wrap(err, "")
-		}
-
-		//write gz
-		writeJSONtoGZ(jsonData, outPath)
-
-		data[i].gzPath = outPath + ".gz"
 	}
-
-	return nil
 }
 
-func readResultJSON(jsonData []byte, p *pack) error {
-
-	var r result
-	err := json.Unmarshal(jsonData, &r)
+func runIndex() error {
+	data, err := db.Load(dbDir, readingFile)
 	if err != nil {
 		return errors.Wrap(err, "")
 	}
+	client := viewer.NewClient(viewerURL)
+	client.DryRun = dryRun
+	return client.UploadIndex(data)
+}
 
-	p.DPS = r.DPS.Mean
-	p.Duration = r.Duration.Mean
-	p.NumTarget = len(r.Targets)
+func runHistory() error {
+	if len(args) != 1 {
+		return errors.New("usage: history [-rollback n] <team>, where team is a file name like bnfsxlxq or its path")
+	}
+	index, err := db.BuildIndex(dbDir)
+	var dup *db.DuplicateError
+	if err != nil && !errors.As(err, &dup) {
+		return errors.Wrap(err, "")
+	}
+	name := strings.TrimSuffix(filepath.Base(args[0]), ".yaml")
+	path, ok := index.Lookup(name)
+	if !ok {
+		return errors.Errorf("no team named %v in %v", name, dbDir)
+	}
+	p, err := db.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
 
-	team := make([]char, 0, len(r.Characters))
+	if rollback == 0 {
+		printHistory(p)
+		return nil
+	}
+	err = p.Rollback(rollback)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	if dryRun {
+		fmt.Printf("[dry-run] would roll %v back to revision %v\n", path, rollback)
+		return nil
+	}
+	err = db.Save([]db.Pack{p}, false)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	fmt.Printf("Rolled %v back to revision %v; it will be rerun next time\n", path, rollback)
+	return nil
+}
 
-	//sort.Slice(r.Characters, func(i, j int) bool { return r.Characters[i].Name < r.Characters[j].Name })
-	/*var q result
-	var names []string
-	for i, v := range r.Characters {
-		names[i] = v.Name
+// printHistory lists the revisions of p with the dps change of each
+func printHistory(p db.Pack) {
+	fmt.Printf("History of %v:\n", p.Path)
+	if len(p.History) == 0 {
+		fmt.Println("\tno revisions recorded yet")
+		return
 	}
-	sort.Strings(names)
-	for i := 0; i < len(names); i++ {
-		for j := 0; j < len(names); j++ {
-			if names[i] == r.Characters[j].Name {
-				q.Characters[i] = r.Characters[j]
+	fmt.Printf("\t%-3v %-20v %-10v %10v %9v  %-24v %v\n", "#", "time", "gcsim", "dps", "change", "submitter", "note")
+	last := 0.0
+	for i, r := range p.History {
+		when := r.Time
+		if when == "" {
+			when = "-"
+		}
+		version, dps, change := r.Version, fmt.Sprintf("%.0f", r.DPS), ""
+		switch {
+		case r.Pending():
+			version, dps = "-", "pending"
+		case r.Superseded:
+			version, dps = "-", "never run"
+		default:
+			if last > 0 {
+				change = fmt.Sprintf("%+.1f%%", (r.DPS-last)/last*100)
 			}
+			last = r.DPS
 		}
-	}*/
-
-	//team info
-	for _, v := range r.Characters {
-		var c char
-		c.Name = v.Name
-		c.Con = v.Cons
-		c.Weapon = v.Weapon.Name
-		c.Refine = v.Weapon.Refine
-		c.Talents = v.Talents
-
-		//grab er stats
-		c.ER = v.Stats[ERIndex]
-
-		team = append(team, c)
+		if len(version) > 10 {
+			version = version[:10]
+		}
+		fmt.Printf("\t%-3v %-20v %-10v %10v %9v  %-24v %v\n", i+1, when, version, dps, change, r.Submitter, r.Note)
 	}
-
-	sort.Slice(team, func(i, j int) bool {
-		return team[i].Name < team[j].Name
-	})
-
-	p.Team = team
-
-	//sort.Slice(p.Team, func(i, j int) bool { return p.Team[i].Name < p.Team[j].Name })
-	return nil
 }
 
-func writeJSONtoGZ(jsonData []byte, fpath string) error {
-	f, err := os.OpenFile(fpath+".gz", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+func runValidate() error {
+	problems, n, err := db.ValidateDir(dbDir)
 	if err != nil {
 		return errors.Wrap(err, "")
 	}
-	defer f.Close()
-	zw := gzip.NewWriter(f)
-	zw.Write(jsonData)
-	err = zw.Close()
-	return errors.Wrap(err, "")
+	for _, p := range problems {
+		fmt.Printf("\t%v\n", p)
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("%v problems found in %v files", len(problems), n)
+	}
+	fmt.Printf("All %v files OK\n", n)
+	return nil
 }
 
-func getVersion() (string, error) {
-	fmt.Println("Getting last hash...")
-	out, err := exec.Command("./gcsim", "-version").Output()
-	hash := strings.Trim(string(out), "\n")
-	fmt.Printf("Latest hash: %v\n", hash)
+// loadCharacters switches to the registry at charsPath so characters added
+// since the build are known
+func loadCharacters() error {
+	if _, err := os.Stat(charsPath); os.IsNotExist(err) {
+		return nil
+	}
+	r, err := db.LoadRegistry(charsPath)
 	if err != nil {
-		return "", err
+		return errors.Wrap(err, "")
 	}
-	return hash, nil
+	db.UseRegistry(r)
+	return nil
 }
 
-func runSim(cfg, path string) error {
-	//write config to file
-	err := os.WriteFile(path+".txt", []byte(cfg), 0755)
+func runAddChar() error {
+	if newCharAliases != "" {
+		for _, a := range strings.Split(newCharAliases, ",") {
+			newChar.Aliases = append(newChar.Aliases, strings.ToLower(strings.TrimSpace(a)))
+		}
+	}
+	r := db.Characters()
+	err := r.Add(newChar)
 	if err != nil {
-		// fmt.Printf("error saving config file: %v\n", err)
 		return errors.Wrap(err, "")
 	}
-	out, err := exec.Command("./gcsim", "-c", path+".txt", "-out", path+".json").Output()
-
+	if dryRun {
+		fmt.Printf("[dry-run] would add %v (%v) to %v\n", newChar.Name, newChar.Abbr, charsPath)
+		return nil
+	}
+	err = r.Save(charsPath)
 	if err != nil {
-		fmt.Printf("%v\n", string(out))
 		return errors.Wrap(err, "")
 	}
+	fmt.Printf("Added %v (%v) to %v\n", newChar.Name, newChar.Abbr, charsPath)
 	return nil
 }
 
-type viewerData struct {
-	Data        string `json:"data"`
-	Author      string `json:"author"`
-	Description string `json:"description"`
+func runServeViewer() error {
+	s, err := viewer.NewLocalServer(serveDir)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	fmt.Printf("Serving viewer from %v on http://%v\n", serveDir, serveAddr)
+	return http.ListenAndServe(serveAddr, s)
 }
 
-type viewerRes struct {
-	ID string `json:"id"`
-}
+// rerunAll grabs the latest gcsim and reruns every stale team in ./db
+func rerunAll(j *journal.Journal) ([]db.Pack, error) {
+	if !skipDownload && !dryRun {
+		//download nightly cmd line build
+		//https://github.com/genshinsim/gcsim/releases/download/nightly/gcsim.exe
+		err := sim.Download(gcsimPath, "https://github.com/genshinsim/gcsim/releases/latest/download/gcsim.exe")
+		if err != nil {
+			return nil, errors.Wrap(err, "")
+		}
+	}
 
-func uploadResults(data []pack) error {
-	//read api key from env
-	err := godotenv.Load()
+	runner := sim.NewExecRunner(gcsimPath, strings.Fields(gcsimArgs)...)
+
+	//grab latest hash
+	hash, err := gcsimVersion(runner)
 	if err != nil {
-		return errors.Wrap(err, "error getting env variable")
+		return nil, errors.Wrap(err, "")
 	}
-	apiKey := os.Getenv("API_KEY")
-	for i, v := range data {
-		//skip if no change and has a viewer key already
-		if !v.changed && v.ViewerKey != "" {
-			continue
+	if resume && j.Version != "" && j.Version != hash {
+		return nil, errors.Errorf("gcsim is now %v but the run being resumed used %v; start a new run instead", hash, j.Version)
+	}
+	if !dryRun {
+		err = j.Update(func(j *journal.Journal) { j.Version = hash })
+		if err != nil {
+			return nil, errors.Wrap(err, "")
 		}
-		//check if key exists, if not generate one
-		key := v.ViewerKey
+	}
 
-		if key == "" {
-			key, err = gonanoid.New()
-			if err != nil {
-				return errors.Wrap(err, "")
-			}
-		}
+	//loop through db folder; check hash
+	data, err := db.Load(dbDir, readingFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
 
-		//read the gz file
-		gzData, err := os.ReadFile(v.gzPath)
-		if err != nil {
-			return errors.Wrap(err, "reading gz data")
-		}
-		b64string := base64.StdEncoding.EncodeToString(gzData)
+	//process
+	opts := rerunOpts
+	opts.Force = force
+	opts.Runner = runner
+	opts.Journal = j
+	opts.Resume = resume
+	opts.DryRun = dryRun
+	err = rerun.Process(data, hash, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
 
-		x := viewerData{
-			Data:        b64string,
-			Author:      v.Author,
-			Description: "team database",
-		}
+	return data, nil
+}
 
-		jsonData, err := json.Marshal(x)
+func runAll() error {
+	if dryRun {
+		return dryRunAll()
+	}
+
+	j, err := openJournal()
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	//update DB with new and updated teams
+	if !force && inputfile != "" && !j.Ingested {
+		err = keepGoing(ingestData())
 		if err != nil {
 			return errors.Wrap(err, "")
 		}
-
-		fmt.Printf("\tUploading results from %v to viewer: ", v.filepath)
-
-		req, err := http.NewRequest("POST", "https://viewer.gcsim.workers.dev/key", bytes.NewBuffer(jsonData))
+		err = j.Update(func(j *journal.Journal) { j.Ingested = true })
 		if err != nil {
-			fmt.Printf("FAILED, error: %v\n", err)
 			return errors.Wrap(err, "")
 		}
-		req.Header.Set("content-type", "application/json")
-		req.Header.Set("API-KEY", apiKey)
-		req.Header.Set("VIEWER_KEY", key)
+	}
 
-		resp, err := http.DefaultClient.Do(req)
+	//allow time to put aside the teams that were updated multiple times
+	pause()
+
+	data, err := rerunAll(j)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	err = db.Save(data, false)
+	if err != nil {
+		//the journal stays so -resume can write the results out again
+		return errors.Wrap(err, "")
+	}
+	//allow time to inspect the teams one last time
+	pause()
+
+	if upload || force {
+		//store on cloudflare kv
+		client := viewer.NewClient(viewerURL)
+		client.OnUpload = func(p db.Pack) error {
+			return j.Mark(p.Path, func(e *journal.Entry) {
+				e.Uploaded = true
+				e.ViewerKey = p.ViewerKey
+			})
+		}
+		//don't upload again what made it to the viewer before the last run stopped
+		for i := range data {
+			if e := j.Get(data[i].Path); e.Uploaded {
+				data[i].Uploaded = true
+				data[i].ViewerKey = e.ViewerKey
+				data[i].RecordUpload()
+			}
+		}
+		err = client.UploadResults(data)
 		if err != nil {
-			fmt.Printf("FAILED, error: %v\n", err)
 			return errors.Wrap(err, "")
 		}
-		if resp.StatusCode != 200 {
-			log.Println(resp.Status)
-			fmt.Printf("FAILED, error: %v\n", resp.Status)
-			return errors.Wrap(errors.New("http post request failed: "+resp.Status), "request failed")
+
+		if !j.Indexed {
+			err = client.UploadIndex(data)
+			if err != nil {
+				return errors.Wrap(err, "")
+			}
+			err = j.Update(func(j *journal.Journal) { j.Indexed = true })
+			if err != nil {
+				return errors.Wrap(err, "")
+			}
 		}
 
-		//otherwise decode key from body
-		var res viewerRes
-		err = json.NewDecoder(resp.Body).Decode(&res)
+		err = db.Save(data, force)
 		if err != nil {
-			fmt.Printf("FAILED, error: %v\n", err)
 			return errors.Wrap(err, "")
 		}
-
-		data[i].ViewerKey = res.ID
-		fmt.Printf("OK, key = %v\n", res.ID)
 	}
-	return nil
-}
 
-func uploadIndex(data []pack) error {
-	//read api key from env
-	err := godotenv.Load()
+	//everything is done, the next run starts from scratch
+	err = j.Remove()
 	if err != nil {
 		return errors.Wrap(err, "")
 	}
-	apiKey := os.Getenv("API_KEY")
 
-	jsonData, err := json.Marshal(data)
+	prettify()
+
+	return nil
+}
+
+// dryRunAll walks through runAll reporting what each step would do. Ingesting
+// doesn't change ./db in a dry run, so the teams it would touch are added to
+// the rerun plan by hand.
+func dryRunAll() error {
+	j, err := openJournal()
 	if err != nil {
 		return errors.Wrap(err, "")
 	}
 
-	fmt.Print("Uploading DB index: ")
+	var touched []string
+	if !force && inputfile != "" && !j.Ingested {
+		touched, err = ingestTouched()
+		if err = keepGoing(err); err != nil {
+			return errors.Wrap(err, "")
+		}
+	}
 
-	req, err := http.NewRequest("POST", "https://viewer.gcsim.workers.dev/db", bytes.NewBuffer(jsonData))
+	hash, err := gcsimVersion(sim.NewExecRunner(gcsimPath, strings.Fields(gcsimArgs)...))
 	if err != nil {
-		fmt.Printf("FAILED, error: %v\n", err)
 		return errors.Wrap(err, "")
 	}
-	req.Header.Set("content-type", "application/json")
-	req.Header.Set("API-KEY", apiKey)
-
-	resp, err := http.DefaultClient.Do(req)
+	data, err := db.Load(dbDir, readingFile)
 	if err != nil {
-		fmt.Printf("FAILED, error: %v\n", err)
 		return errors.Wrap(err, "")
 	}
-	if resp.StatusCode != 200 {
-		log.Println(resp.Status)
-		fmt.Printf("FAILED, error: %v\n", resp.Status)
-		return errors.Wrap(errors.New("http post request failed: "+resp.Status), "request failed")
-	}
-
-	fmt.Print("OK\n")
 
-	return nil
-}
-
-func saveYaml(data []pack) error {
+	opts := rerunOpts
+	opts.Force = force
+	opts.Journal = j
+	opts.Resume = resume
+	plan := rerun.Plan(data, hash, opts)
+	total := len(data)
+	for _, path := range touched {
+		found := false
+		for _, p := range plan {
+			found = found || filepath.Clean(p.Path) == filepath.Clean(path)
+		}
+		if found {
+			continue
+		}
+		plan = append(plan, rerun.PlanItem{Path: path, Reason: "new or updated submission"})
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			total++
+		}
+	}
+	rerun.PrintPlan(plan, total, hash)
 
-	for i := range data {
-		//sort.Slice(data[i].Team, func(k, j int) bool { return data[i].Team[k].Name < data[i].Team[j].Name })
-		//overwrite yaml
-		out, err := yaml.Marshal(data[i])
-		if err != nil {
-			return errors.Wrap(err, "")
+	if upload || force {
+		for _, p := range plan {
+			if e := j.Get(p.Path); !e.Uploaded {
+				fmt.Printf("\t[dry-run] would upload results from %v\n", p.Path)
+			}
 		}
-		os.Remove(data[i].filepath)
-		err = os.WriteFile(data[i].filepath2, data[i].Config, 0755)
-		err = os.WriteFile(data[i].filepath, out, 0755)
-		if err != nil {
-			return errors.Wrap(err, "")
+		if !j.Indexed {
+			fmt.Printf("[dry-run] would upload DB index to %v\n", viewerURL)
+		}
+		if force {
+			fmt.Println("[dry-run] rerun teams would be moved to the folder of their main dps where it changed")
 		}
 	}
+
 	return nil
 }
 
-// func cloneRepo() (string, error) {
-// 	//check if tmp folder already exists, if so remove
-// 	if _, err := os.Stat("./tmp"); !os.IsNotExist(err) {
-// 		fmt.Println("tmp folder already exists, deleting...")
-// 		// path/to/whatever exists
-// 		os.RemoveAll("./tmp/")
-// 	}
-
-// 	fmt.Println("Starting to clone git repo...")
-
-// 	r, err := git.PlainClone("./tmp", false, &git.Clonehurt every interval=0,1200 amount=0,3000;
-  options swap_delay=12{
-// 		URL:               "https://github.com/genshinsim/gcsim.git",
-// 		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
-// 	})
-// 	if err != nil {
-// 		return "", err
-// 	}
-
-// 	ref, err := r.Head()
-// 	if err != nil {
-// 		return "", err
-// 	}
-
-// 	hs := fmt.Sprint(ref.Hash())
-
-// 	fmt.Printf("Git repo cloned successfully, latest hash: %v\n", hs)
-// 	return hs, nil
-// }
+// prettify runs prettier over ./db so hand edits and generated files look the same
+func prettify() {
+	cmd := exec.Command("cmd", "/C", "npx prettier --write --end-of-line=crlf db/**")
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		fmt.Println(fmt.Sprint(err) + ": " + stderr.String())
+	}
+}
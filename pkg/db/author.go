@@ -0,0 +1,142 @@
+package db
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// Contribution types
+const (
+	//sent in the team in the first place
+	RoleOriginal = "original"
+	//sent in an improved version of it later
+	RoleUpdate = "update"
+)
+
+// Contributor is someone credited for a team
+type Contributor struct {
+	Name string `yaml:"name" json:"name"`
+	//Discord discriminator, without the #
+	Tag string `yaml:"tag,omitempty" json:"tag,omitempty"`
+	//date of their first contribution to the team, as YYYY-MM-DD; unknown for
+	//credits carried over from the old author string
+	Since string `yaml:"since,omitempty" json:"since,omitempty"`
+	Role  string `yaml:"role,omitempty" json:"role,omitempty"`
+}
+
+// String renders c the way Discord does, as name#tag
+func (c Contributor) String() string {
+	if c.Tag == "" {
+		return c.Name
+	}
+	return c.Name + "#" + c.Tag
+}
+
+// same reports whether c and o are the same person
+func (c Contributor) same(o Contributor) bool {
+	return strings.EqualFold(strings.TrimSpace(c.Name), strings.TrimSpace(o.Name)) && c.Tag == o.Tag
+}
+
+// Authors is the list of people credited for a team, in the order they
+// contributed. Files written before it existed have a single string like
+// "A#1234, B#5678 and C#9012", which is still read.
+type Authors []Contributor
+
+// reTag matches a Discord tag: exactly four digits, so the character after it
+// belongs to the next name
+var reTag = regexp.MustCompile(`#(\d{4})(?:\D|$)`)
+var reSep = regexp.MustCompile(`^\s*(,|and\s)?\s*`)
+
+// ParseAuthors reads an author string as written by hand or by older versions
+// of this tool. Each Discord tag ends a contributor; anything after the last
+// tag is one more contributor without a tag. The first is credited as the
+// original author, the rest as updates.
+func ParseAuthors(s string) Authors {
+	var a Authors
+	add := func(name, tag string) {
+		name = strings.TrimSpace(reSep.ReplaceAllString(name, ""))
+		if name == "" && tag == "" {
+			return
+		}
+		role := RoleUpdate
+		if len(a) == 0 {
+			role = RoleOriginal
+		}
+		a.Credit(Contributor{Name: name, Tag: tag, Role: role})
+	}
+
+	last := 0
+	for {
+		//search from the end of the last tag, not of the last match
+		m := reTag.FindStringSubmatchIndex(s[last:])
+		if m == nil {
+			break
+		}
+		add(s[last:last+m[0]], s[last+m[2]:last+m[3]])
+		last += m[3]
+	}
+	add(s[last:], "")
+	return a
+}
+
+// String renders a as a single line for the viewer: "A#1", "A#1 and B#2" or
+// "A#1, B#2 and C#3"
+func (a Authors) String() string {
+	var names []string
+	for _, c := range a {
+		names = append(names, c.String())
+	}
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// Credit adds c unless they are already credited, reporting whether they were added
+func (a *Authors) Credit(c Contributor) bool {
+	for _, o := range *a {
+		if o.same(c) {
+			return false
+		}
+	}
+	*a = append(*a, c)
+	return true
+}
+
+// UnmarshalYAML reads either a list of contributors or an old author string
+func (a *Authors) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		*a = ParseAuthors(s)
+		return nil
+	}
+	var list []Contributor
+	err := unmarshal(&list)
+	if err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// MarshalJSON renders a as the author string the viewer and db index expect
+func (a Authors) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON reads what MarshalJSON writes, or a list of contributors
+func (a *Authors) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = ParseAuthors(s)
+		return nil
+	}
+	var list []Contributor
+	err := json.Unmarshal(b, &list)
+	if err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
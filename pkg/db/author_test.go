@@ -0,0 +1,114 @@
+package db
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestParseAuthors(t *testing.T) {
+	orig := func(name, tag string) Contributor { return Contributor{Name: name, Tag: tag, Role: RoleOriginal} }
+	upd := func(name, tag string) Contributor { return Contributor{Name: name, Tag: tag, Role: RoleUpdate} }
+	cases := []struct {
+		in   string
+		want Authors
+	}{
+		{"", nil},
+		{"Bob#1234", Authors{orig("Bob", "1234")}},
+		{"Bob", Authors{orig("Bob", "")}},
+		{"A#1234 and B#5678", Authors{orig("A", "1234"), upd("B", "5678")}},
+		{"A#1234, B#5678 and C#9012", Authors{orig("A", "1234"), upd("B", "5678"), upd("C", "9012")}},
+		{"A#1234,B#5678", Authors{orig("A", "1234"), upd("B", "5678")}},
+		//names with # or "and" in them
+		{"Sand#1234 and Andy#5678", Authors{orig("Sand", "1234"), upd("Andy", "5678")}},
+		{"C# fan#1234", Authors{orig("C# fan", "1234")}},
+		{"x#12345", Authors{orig("x#12345", "")}},
+		{"A#1234#5678", Authors{orig("A", "1234"), upd("", "5678")}},
+		//one tag inside another isn't the same person
+		{"A#1234 and A#12345", Authors{orig("A", "1234"), upd("A#12345", "")}},
+		{"A#1234 and B", Authors{orig("A", "1234"), upd("B", "")}},
+		{"lulu ~𝔂 ♡#4236", Authors{orig("lulu ~𝔂 ♡", "4236")}},
+		//the same person credited twice
+		{"A#1234 and a#1234", Authors{orig("A", "1234")}},
+		{"A#1234 and A#4321", Authors{orig("A", "1234"), upd("A", "4321")}},
+	}
+	for _, c := range cases {
+		if got := ParseAuthors(c.in); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %+v, want %+v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestAuthorsString(t *testing.T) {
+	cases := []struct {
+		in   Authors
+		want string
+	}{
+		{nil, ""},
+		{Authors{{Name: "A", Tag: "1234"}}, "A#1234"},
+		{Authors{{Name: "A", Tag: "1234"}, {Name: "B"}}, "A#1234 and B"},
+		{Authors{{Name: "A", Tag: "1234"}, {Name: "B", Tag: "5678"}, {Name: "C", Tag: "9012"}}, "A#1234, B#5678 and C#9012"},
+	}
+	for _, c := range cases {
+		if got := c.in.String(); got != c.want {
+			t.Errorf("%+v: got %q, want %q", c.in, got, c.want)
+		}
+		//what the viewer gets must read back the same
+		if got := ParseAuthors(c.want).String(); got != c.want {
+			t.Errorf("%q doesn't round trip, got %q", c.want, got)
+		}
+	}
+}
+
+func TestAuthorsUnmarshal(t *testing.T) {
+	var p struct {
+		Author Authors `yaml:"author"`
+	}
+	err := yaml.Unmarshal([]byte("author: A#1234 and B#5678\n"), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Author.String() != "A#1234 and B#5678" {
+		t.Errorf("old string read as %+v", p.Author)
+	}
+
+	err = yaml.Unmarshal([]byte("author:\n  - name: A\n    tag: \"1234\"\n    since: 2022-01-02\n    role: original\n"), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Authors{{Name: "A", Tag: "1234", Since: "2022-01-02", Role: RoleOriginal}}
+	if !reflect.DeepEqual(p.Author, want) {
+		t.Errorf("list read as %+v, want %+v", p.Author, want)
+	}
+
+	b, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `"A#1234"` {
+		t.Errorf("json = %s, want the author string", b)
+	}
+	var back Authors
+	err = json.Unmarshal(b, &back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.String() != "A#1234" {
+		t.Errorf("json read back as %+v", back)
+	}
+}
+
+func TestCredit(t *testing.T) {
+	a := Authors{{Name: "Bob", Tag: "1234"}}
+	if a.Credit(Contributor{Name: " bob ", Tag: "1234"}) {
+		t.Error("credited the same person twice")
+	}
+	if !a.Credit(Contributor{Name: "Bob", Tag: "12"}) {
+		t.Error("a different tag is a different person")
+	}
+	if len(a) != 2 {
+		t.Errorf("got %+v", a)
+	}
+}
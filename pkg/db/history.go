@@ -0,0 +1,192 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/genshinsim/gcsimdb/pkg/sim"
+	"github.com/pkg/errors"
+)
+
+// Revision is one version of a team's config and the results it got. A
+// revision starts out pending when a config is submitted (or rolled back to)
+// and gets its results from the next rerun; a rerun on a new gcsim version
+// adds a revision of its own.
+type Revision struct {
+	//when the config was submitted or the rerun happened, RFC 3339; empty for
+	//the state a team was in before history was kept
+	Time string `yaml:"time,omitempty"`
+	//only kept when it differs from the revision before; see configFor
+	Config     string  `yaml:"config,omitempty"`
+	ConfigHash string  `yaml:"config_hash"`
+	DPS        float64 `yaml:"dps"`
+	//gcsim version the results are from; empty while pending
+	Version   string `yaml:"version,omitempty"`
+	Submitter string `yaml:"submitter,omitempty"`
+	ViewerKey string `yaml:"viewer_key,omitempty"`
+	Note      string `yaml:"note,omitempty"`
+	//a newer config was submitted or rolled back to before this one was rerun
+	Superseded bool `yaml:"superseded,omitempty"`
+}
+
+// Pending reports whether r is still waiting for a rerun
+func (r Revision) Pending() bool {
+	return r.Version == "" && !r.Superseded
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// seedHistory records the results prev had as the first revision of p, so
+// teams from before history was kept don't lose them
+func (p *Pack) seedHistory(prev Pack) {
+	if len(p.History) > 0 || prev.Hash == "" {
+		return
+	}
+	hash := prev.ConfigHash
+	if hash == "" {
+		hash = sim.ConfigHash(prev.Config)
+	}
+	p.History = []Revision{{
+		Config:     prev.Config,
+		ConfigHash: hash,
+		DPS:        prev.DPS,
+		Version:    prev.Hash,
+		Submitter:  prev.Author.String(),
+		ViewerKey:  prev.ViewerKey,
+		Note:       "before history was kept",
+	}}
+}
+
+// addRevision appends r without touching the history p may share with a copy
+// of it. Reruns of the same config don't store it again.
+func (p *Pack) addRevision(r Revision) {
+	n := len(p.History)
+	if n > 0 && p.History[n-1].ConfigHash == r.ConfigHash {
+		r.Config = ""
+	}
+	history := make([]Revision, n, n+1)
+	copy(history, p.History)
+	p.History = append(history, r)
+}
+
+// replaceRevision puts r in place of the last revision of p
+func (p *Pack) replaceRevision(r Revision) {
+	p.History = p.History[: len(p.History)-1 : len(p.History)-1]
+	p.addRevision(r)
+}
+
+// supersedePending marks the last revision of p as never going to be rerun if
+// it is still pending, as a newer config is about to take its place. It stays
+// in the history so its config and submitter aren't lost.
+func (p *Pack) supersedePending() {
+	if !p.pendingRevision() {
+		return
+	}
+	n := len(p.History)
+	history := make([]Revision, n, n+1)
+	copy(history, p.History)
+	history[n-1].Superseded = true
+	history[n-1].Note += "; superseded, never rerun"
+	p.History = history
+}
+
+// pendingRevision reports whether the last revision of p is waiting for a rerun
+func (p *Pack) pendingRevision() bool {
+	n := len(p.History)
+	return n > 0 && p.History[n-1].Pending()
+}
+
+// configFor finds the config with hash in the history of p
+func (p *Pack) configFor(hash string) (string, bool) {
+	for i := len(p.History) - 1; i >= 0; i-- {
+		if r := p.History[i]; r.ConfigHash == hash && r.Config != "" {
+			return r.Config, true
+		}
+	}
+	return "", false
+}
+
+// Submit replaces the config of p with one sent in by submitter, keeping the
+// old one in the history. The team reruns with it next time; a config
+// submitted before that is kept but marked as superseded.
+func (p *Pack) Submit(config string, submitter string) {
+	p.seedHistory(*p)
+	p.supersedePending()
+	p.addRevision(Revision{
+		Time:       now(),
+		Config:     config,
+		ConfigHash: sim.ConfigHash(config),
+		Submitter:  submitter,
+		Note:       "submitted",
+	})
+	p.Config = config
+	//no hash means the next rerun picks it up
+	p.Hash = ""
+}
+
+// RecordResult stores the results p was just rerun with in its history:
+// they complete the pending revision for the same config, or make a new one.
+// prev is the pack as it was before the rerun.
+func (p *Pack) RecordResult(prev Pack) {
+	p.seedHistory(prev)
+	hash := sim.ConfigHash(p.Config)
+	rev := Revision{
+		Time:       now(),
+		Config:     p.Config,
+		ConfigHash: hash,
+		DPS:        p.DPS,
+		Version:    p.Hash,
+		Note:       "rerun",
+	}
+	if n := len(p.History); n > 0 && p.History[n-1].Pending() && p.History[n-1].ConfigHash == hash {
+		last := p.History[n-1]
+		rev.Submitter = last.Submitter
+		rev.Note = last.Note
+		p.replaceRevision(rev)
+		return
+	}
+	if n := len(p.History); n > 0 {
+		rev.Submitter = p.History[n-1].Submitter
+	}
+	p.addRevision(rev)
+}
+
+// RecordUpload notes the viewer key the latest results were uploaded under
+func (p *Pack) RecordUpload() {
+	n := len(p.History)
+	if n == 0 || p.History[n-1].Pending() {
+		return
+	}
+	history := make([]Revision, n)
+	copy(history, p.History)
+	history[n-1].ViewerKey = p.ViewerKey
+	p.History = history
+}
+
+// Rollback puts the config of revision n (counting from 1) back in place as
+// a new pending revision; the team reruns with it next time
+func (p *Pack) Rollback(n int) error {
+	if n < 1 || n > len(p.History) {
+		return errors.Errorf("no revision %v, %v has %v", n, p.Path, len(p.History))
+	}
+	old := p.History[n-1]
+	config, ok := p.configFor(old.ConfigHash)
+	if !ok {
+		return errors.Errorf("config of revision %v of %v isn't in its history", n, p.Path)
+	}
+	p.supersedePending()
+	p.addRevision(Revision{
+		Time:       now(),
+		Config:     config,
+		ConfigHash: old.ConfigHash,
+		Submitter:  old.Submitter,
+		Note:       fmt.Sprintf("rollback to revision %v", n),
+	})
+	p.Config = config
+	//no hash means the next rerun picks it up
+	p.Hash = ""
+	p.Changed = true
+	return nil
+}
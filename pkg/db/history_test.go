@@ -0,0 +1,156 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/genshinsim/gcsimdb/pkg/sim"
+)
+
+// rerunPack does what a rerun does to p: new results on version, recorded in the history
+func rerunPack(p *Pack, version string, dps float64) {
+	prev := *p
+	p.Hash = version
+	p.ConfigHash = sim.ConfigHash(p.Config)
+	p.DPS = dps
+	p.RecordResult(prev)
+}
+
+func TestSubmitSeedsHistory(t *testing.T) {
+	p := Pack{Config: "old;", Hash: "v1", DPS: 100, Author: ParseAuthors("A#1234")}
+	p.Submit("new;", "B#5678")
+
+	if len(p.History) != 2 {
+		t.Fatalf("history = %+v, want the old state and the submission", p.History)
+	}
+	seed, sub := p.History[0], p.History[1]
+	if seed.Config != "old;" || seed.Version != "v1" || seed.DPS != 100 || seed.Submitter != "A#1234" {
+		t.Errorf("seed = %+v", seed)
+	}
+	if sub.Config != "new;" || !sub.Pending() || sub.Submitter != "B#5678" {
+		t.Errorf("submission = %+v", sub)
+	}
+	if p.Config != "new;" || p.Hash != "" {
+		t.Errorf("config = %q, hash = %q; want the new config waiting for a rerun", p.Config, p.Hash)
+	}
+}
+
+func TestSubmitTwiceBeforeRerun(t *testing.T) {
+	p := Pack{Config: "old;", Hash: "v1", DPS: 100}
+	p.Submit("first;", "A#1234")
+	p.Submit("second;", "B#5678")
+
+	if len(p.History) != 3 {
+		t.Fatalf("history = %+v, want both submissions kept", p.History)
+	}
+	first := p.History[1]
+	if first.Config != "first;" || first.Submitter != "A#1234" || first.Pending() || !first.Superseded {
+		t.Errorf("first submission = %+v, want it kept and superseded", first)
+	}
+	if !p.History[2].Pending() {
+		t.Errorf("second submission = %+v, want it pending", p.History[2])
+	}
+
+	rerunPack(&p, "v2", 200)
+	if len(p.History) != 3 {
+		t.Fatalf("history = %+v, want the rerun to complete the pending revision", p.History)
+	}
+	last := p.History[2]
+	if last.Config != "second;" || last.Version != "v2" || last.DPS != 200 || last.Submitter != "B#5678" || last.Note != "submitted" {
+		t.Errorf("completed revision = %+v", last)
+	}
+}
+
+func TestRecordResult(t *testing.T) {
+	p := Pack{Config: "cfg;", Hash: "v1", DPS: 100, Author: ParseAuthors("A#1234")}
+	rerunPack(&p, "v2", 110)
+	rerunPack(&p, "v3", 120)
+
+	if len(p.History) != 3 {
+		t.Fatalf("history = %+v, want the seed and two reruns", p.History)
+	}
+	for i, want := range []struct {
+		version string
+		dps     float64
+	}{{"v1", 100}, {"v2", 110}, {"v3", 120}} {
+		r := p.History[i]
+		if r.Version != want.version || r.DPS != want.dps {
+			t.Errorf("revision %v = %+v, want %v at %v dps", i+1, r, want.version, want.dps)
+		}
+		if r.Submitter != "A#1234" {
+			t.Errorf("revision %v submitter = %q", i+1, r.Submitter)
+		}
+	}
+	//reruns of the same config don't store it again
+	if p.History[0].Config != "cfg;" || p.History[1].Config != "" || p.History[2].Config != "" {
+		t.Errorf("configs = %q, %q, %q; want only the first stored", p.History[0].Config, p.History[1].Config, p.History[2].Config)
+	}
+}
+
+func TestRecordResultDoesNotShareHistory(t *testing.T) {
+	p := Pack{Config: "cfg;", Hash: "v1", DPS: 100}
+	rerunPack(&p, "v2", 110)
+	before := p
+	rerunPack(&p, "v3", 120)
+	if len(before.History) != 2 || before.History[1].Version != "v2" {
+		t.Errorf("copy of the pack changed: %+v", before.History)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	p := Pack{Config: "a;", Hash: "v1", DPS: 100}
+	rerunPack(&p, "v2", 110)
+	p.Submit("b;", "B#5678")
+	rerunPack(&p, "v2", 90)
+
+	//revision 2 is a rerun of a; that only the first revision stores
+	err := p.Rollback(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Config != "a;" || p.Hash != "" || !p.Changed {
+		t.Errorf("config = %q, hash = %q, changed = %v; want a waiting for a rerun", p.Config, p.Hash, p.Changed)
+	}
+	last := p.History[len(p.History)-1]
+	if last.Config != "a;" || !last.Pending() || last.Note != "rollback to revision 2" {
+		t.Errorf("rollback revision = %+v", last)
+	}
+	if len(p.History) != 4 {
+		t.Errorf("history has %v revisions, want 4", len(p.History))
+	}
+
+	if err := p.Rollback(0); err == nil {
+		t.Error("want an error rolling back to revision 0")
+	}
+	if err := p.Rollback(len(p.History) + 1); err == nil {
+		t.Error("want an error rolling back past the last revision")
+	}
+}
+
+func TestRollbackWhileSubmissionPending(t *testing.T) {
+	p := Pack{Config: "a;", Hash: "v1", DPS: 100}
+	p.Submit("b;", "B#5678")
+
+	err := p.Rollback(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.History) != 3 {
+		t.Fatalf("history = %+v, want the submission kept", p.History)
+	}
+	sub := p.History[1]
+	if sub.Config != "b;" || sub.Submitter != "B#5678" || !sub.Superseded {
+		t.Errorf("submission = %+v, want it kept and superseded", sub)
+	}
+	if p.Config != "a;" || !p.History[2].Pending() {
+		t.Errorf("config = %q, last = %+v", p.Config, p.History[2])
+	}
+
+	//the superseded submission can still be rolled back to
+	err = p.Rollback(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Config != "b;" {
+		t.Errorf("config = %q, want b;", p.Config)
+	}
+}
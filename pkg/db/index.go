@@ -0,0 +1,96 @@
+package db
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Index finds team files by name. Names are the file name without .yaml,
+// variant suffix included, and must be unique across every folder of the db.
+type Index struct {
+	paths map[string]string
+}
+
+// DuplicateError lists team names found in more than one file
+type DuplicateError struct {
+	Paths map[string][]string
+}
+
+func (e *DuplicateError) Error() string {
+	var names []string
+	for name := range e.Paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var msgs []string
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%v.yaml is in %v", name, strings.Join(e.Paths[name], " and ")))
+	}
+	return fmt.Sprintf("%v teams have more than one file:\n\t%v", len(names), strings.Join(msgs, "\n\t"))
+}
+
+// BuildIndex indexes every team file under dir. If a name is used more than
+// once the index is still returned, along with a *DuplicateError.
+func BuildIndex(dir string) (*Index, error) {
+	x := &Index{paths: make(map[string]string)}
+	dups := make(map[string][]string)
+	err := filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+		if info.IsDir() || filepath.Ext(path) != ".yaml" {
+			return nil
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".yaml")
+		if first, ok := x.paths[name]; ok {
+			if len(dups[name]) == 0 {
+				dups[name] = []string{first}
+			}
+			dups[name] = append(dups[name], path)
+			return nil
+		}
+		x.paths[name] = path
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(dups) > 0 {
+		return x, &DuplicateError{Paths: dups}
+	}
+	return x, nil
+}
+
+// Lookup returns the path of the team file named name
+func (x *Index) Lookup(name string) (string, bool) {
+	path, ok := x.paths[name]
+	return path, ok
+}
+
+// Add records a new team file, failing if the name is already taken by
+// another file
+func (x *Index) Add(path string) error {
+	name := strings.TrimSuffix(filepath.Base(path), ".yaml")
+	if old, ok := x.paths[name]; ok && old != path {
+		return errors.Errorf("%v.yaml is already in %v", name, old)
+	}
+	x.paths[name] = path
+	return nil
+}
+
+// Variants returns the files of every variant of the team named name, keyed
+// by variant number
+func (x *Index) Variants(name string) map[int]string {
+	found := make(map[int]string)
+	for n, path := range x.paths {
+		if base, v := SplitVariant(n); base == name {
+			found[v] = path
+		}
+	}
+	return found
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/genshinsim/gcsimdb/pkg/sim"
+	"github.com/pkg/errors"
+)
+
+// GetName builds the file name of a team from the sorted abbreviations of its
+// characters, padding teams of fewer than four with Paimon
+func GetName(data sim.Result) (string, error) {
+	var keys []string
+	for _, c := range data.Characters {
+		keys = append(keys, c.Name)
+	}
+	return nameForKeys(keys)
+}
+
+// Folder returns the db folder for a gcsim character key
+func Folder(key string) (string, error) {
+	c, err := Characters().Lookup(key)
+	if err != nil {
+		return "", err
+	}
+	return c.Name, nil
+}
+
+// TeamPath is where a team with result r belongs under dir: the folder of its
+// main dps and the name from GetName
+func TeamPath(dir string, r sim.Result) (string, error) {
+	main := r.MainDPS()
+	if main < 0 || main >= len(r.Characters) {
+		return "", errors.New("result has no damage breakdown to pick a main dps from")
+	}
+	folder, err := Folder(r.Characters[main].Name)
+	if err != nil {
+		return "", err
+	}
+	name, err := GetName(r)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, folder, name+".yaml"), nil
+}
+
+// nameForKeys is GetName for a list of gcsim keys
+func nameForKeys(keys []string) (string, error) {
+	if len(keys) > 4 {
+		return "", errors.Errorf("team has %v characters, want at most 4", len(keys))
+	}
+	names := []string{"Paimon", "Paimon", "Paimon", "Paimon"}
+	for i, k := range keys {
+		c, err := Characters().Lookup(k)
+		if err != nil {
+			return "", err
+		}
+		names[i] = c.Name
+	}
+	sort.Strings(names)
+	fname := ""
+	for i := range names {
+		c, err := Characters().ByName(names[i])
+		if err != nil {
+			return "", err
+		}
+		fname += c.Abbr
+	}
+	return fname, nil
+}
+
+// VariantName is the file name of variant n of the team named name. The first
+// variant keeps the plain name so existing teams don't move.
+func VariantName(name string, n int) string {
+	if n <= 1 {
+		return name
+	}
+	return fmt.Sprintf("%v-%v", name, n)
+}
+
+// SplitVariant splits a file name made by VariantName back into the team
+// name and variant number
+func SplitVariant(base string) (string, int) {
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	i := strings.LastIndex(base, "-")
+	if i < 0 {
+		return base, 1
+	}
+	n, err := strconv.Atoi(base[i+1:])
+	if err != nil || n < 2 {
+		return base, 1
+	}
+	return base[:i], n
+}
@@ -0,0 +1,229 @@
+// Package db reads and writes the team database: one yaml file per team,
+// grouped into a folder per main dps character.
+package db
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/genshinsim/gcsimdb/pkg/sim"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+type Pack struct {
+	Author      Authors `yaml:"author" json:"author"`
+	Config      string  `yaml:"config" json:"config"`
+	Description string  `yaml:"description" json:"description"`
+	//the following are machine generated fields
+	Hash       string  `yaml:"hash" json:"hash"`
+	ConfigHash string  `yaml:"config_hash" json:"config_hash"`
+	Team       []Char  `yaml:"team" json:"team"`
+	DPS        float64 `yaml:"dps" json:"dps"`
+	//spread of the dps over the iterations; DPS is its mean
+	DPSStats  Stats   `yaml:"dps_stats,omitempty" json:"dps_stats"`
+	Mode      string  `yaml:"mode" json:"mode"`
+	Duration  float64 `yaml:"duration" json:"duration"`
+	NumTarget int     `yaml:"target_count" json:"target_count"`
+	ViewerKey string  `yaml:"viewer_key" json:"viewer_key"`
+	//every config the team has had and the results it got, oldest first
+	History []Revision `yaml:"history,omitempty" json:"-"`
+	//not stored; filled in while loading and rerunning
+	Path    string `yaml:"-" json:"-"`
+	GzPath  string `yaml:"-" json:"-"`
+	Changed bool   `yaml:"-" json:"-"`
+	//result is already on the viewer under ViewerKey
+	Uploaded bool       `yaml:"-" json:"-"`
+	Result   sim.Result `yaml:"-" json:"-"`
+}
+
+type Char struct {
+	Name    string           `yaml:"name" json:"name"`
+	Con     int              `yaml:"con" json:"con"`
+	Weapon  string           `yaml:"weapon" json:"weapon"`
+	Refine  int              `yaml:"refine" json:"refine"`
+	ER      float64          `yaml:"er" json:"er"`
+	Talents sim.TalentDetail `yaml:"talents" json:"talents"`
+	//mean dps against each target, first target first
+	DPS []float64 `yaml:"dps,omitempty,flow" json:"dps"`
+	//share of the team's dps against each target, in percent
+	DPSPct []float64 `yaml:"dps_pct,omitempty,flow" json:"dps_pct"`
+}
+
+// Load reads every team file under dir, calling progress, if set, with the
+// path of each before reading it
+func Load(dir string, progress func(path string)) ([]Pack, error) {
+	var data []Pack
+
+	err := filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+		//do nothing if is directory
+		if info.IsDir() {
+			return nil
+		}
+		if progress != nil {
+			progress(path)
+		}
+		d, err := ReadFile(path)
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+
+		data = append(data, d)
+
+		return nil
+	})
+
+	return data, err
+}
+
+// ReadFile reads a single team file
+func ReadFile(path string) (Pack, error) {
+	var d Pack
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return d, errors.Wrap(err, "")
+	}
+	err = yaml.Unmarshal(file, &d)
+	if err != nil {
+		return d, errors.Wrap(err, "")
+	}
+	d.Path = path
+	return d, nil
+}
+
+// Save writes every pack back to its file. With relocate, teams that were
+// rerun are moved to the folder and name derived from their result.
+func Save(data []Pack, relocate bool) error {
+
+	for i := range data {
+		//overwrite yaml
+		out, err := yaml.Marshal(data[i])
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+		path, err := Destination(data[i], relocate)
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+		//the main dps may not have a folder yet
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+		os.Remove(data[i].Path)
+		err = os.WriteFile(path, out, 0755)
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+	}
+	return nil
+}
+
+// Destination is where Save writes p. Without relocate that's always where it
+// was read from.
+func Destination(p Pack, relocate bool) (string, error) {
+	if !relocate || !p.Changed || strings.Contains(p.Path, "DNU") {
+		return p.Path, nil
+	}
+	//db root is two levels up from the team file
+	path, err := TeamPath(filepath.Dir(filepath.Dir(p.Path)), p.Result)
+	if err != nil {
+		return "", err
+	}
+	//a variant stays a variant wherever it moves to
+	if _, n := SplitVariant(filepath.Base(p.Path)); n > 1 {
+		name, _ := SplitVariant(filepath.Base(path))
+		path = filepath.Join(filepath.Dir(path), VariantName(name, n)+".yaml")
+	}
+	return path, nil
+}
+
+// MainDPS returns the character of the team doing the most damage to the
+// first target, or false if p has no damage breakdown
+func (p Pack) MainDPS() (Char, bool) {
+	best := -1
+	for i, c := range p.Team {
+		if len(c.DPS) > 0 && c.DPS[0] > 0 && (best < 0 || c.DPS[0] > p.Team[best].DPS[0]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return Char{}, false
+	}
+	return p.Team[best], true
+}
+
+// ApplyResult copies the team and dps summary of a sim result into p
+func (p *Pack) ApplyResult(r sim.Result) error {
+	//older gcsim builds don't report how many iterations they ran
+	n := r.Iterations
+	if n == 0 {
+		n = sim.Iterations(p.Config)
+	}
+	p.DPS = r.DPS.Mean
+	p.DPSStats = NewStats(r.DPS, n)
+	p.Duration = r.Duration.Mean
+	p.NumTarget = len(r.Targets)
+
+	team := make([]Char, 0, len(r.Characters))
+
+	//damage breakdown; char_details and damage_by_char_by_targets are in the same order
+	targets := len(r.Targets)
+	for _, v := range r.CharDPS {
+		if v.Targets() > targets {
+			targets = v.Targets()
+		}
+	}
+	if len(r.CharDPS) != len(r.Characters) {
+		targets = 0
+	}
+	total := make([]float64, targets)
+	for _, v := range r.CharDPS {
+		for t := range total {
+			total[t] += v.Target(t).Mean
+		}
+	}
+
+	//team info
+	for i, v := range r.Characters {
+		var c Char
+		c.Name = v.Name
+		c.Con = v.Cons
+		c.Weapon = v.Weapon.Name
+		c.Refine = v.Weapon.Refine
+		c.Talents = v.Talents
+
+		//grab er stats
+		if len(v.Stats) <= sim.ERIndex {
+			return errors.Errorf("missing stats for %v", v.Name)
+		}
+		c.ER = v.Stats[sim.ERIndex]
+
+		for t := range total {
+			dps := r.CharDPS[i].Target(t).Mean
+			pct := 0.0
+			if total[t] > 0 {
+				pct = dps / total[t] * 100
+			}
+			c.DPS = append(c.DPS, dps)
+			c.DPSPct = append(c.DPSPct, pct)
+		}
+
+		team = append(team, c)
+	}
+
+	sort.Slice(team, func(i, j int) bool {
+		return team[i].Name < team[j].Name
+	})
+
+	p.Team = team
+	p.Result = r
+
+	return nil
+}
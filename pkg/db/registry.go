@@ -0,0 +1,177 @@
+package db
+
+import (
+	_ "embed"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// RegistryVersion is the characters file format this build reads and writes
+const RegistryVersion = 1
+
+// Character is one entry of the character registry
+type Character struct {
+	//name gcsim reports in results
+	Key string `yaml:"key"`
+	//display name, also the db folder
+	Name string `yaml:"name"`
+	//two letters used to build team file names
+	Abbr    string `yaml:"abbr"`
+	Element string `yaml:"element"`
+	Weapon  string `yaml:"weapon"`
+	//other names gcsim accepts in configs
+	Aliases []string `yaml:"aliases,omitempty,flow"`
+}
+
+// Registry is the list of characters the db knows about, loaded from a
+// characters.yaml file
+type Registry struct {
+	Version    int         `yaml:"version"`
+	Characters []Character `yaml:"characters"`
+
+	byKey  map[string]int
+	byName map[string]int
+}
+
+//go:embed characters.yaml
+var builtinRegistry []byte
+
+// registry holds the *Registry lookups go through. UseRegistry swaps it whole
+// so a lookup never sees half a registry.
+var registry atomic.Value
+
+func init() {
+	registry.Store(mustParseRegistry(builtinRegistry))
+}
+
+func mustParseRegistry(b []byte) *Registry {
+	r, err := ParseRegistry(b)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Characters returns the registry in use
+func Characters() *Registry {
+	return registry.Load().(*Registry)
+}
+
+// UseRegistry replaces the registry every lookup in this package goes
+// through. Call it before looking anything up: names already built from the
+// old registry are not redone.
+func UseRegistry(r *Registry) {
+	registry.Store(r)
+}
+
+// LoadRegistry reads a characters file from path
+func LoadRegistry(path string) (*Registry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading characters")
+	}
+	r, err := ParseRegistry(b)
+	if err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+	return r, nil
+}
+
+// ParseRegistry reads a characters file, checking that keys, aliases, names and
+// abbreviations don't clash
+func ParseRegistry(b []byte) (*Registry, error) {
+	var r Registry
+	err := yaml.UnmarshalStrict(b, &r)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	if r.Version != RegistryVersion {
+		return nil, errors.Errorf("characters file is version %v, want %v", r.Version, RegistryVersion)
+	}
+	err = r.index()
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (r *Registry) index() error {
+	r.byKey = make(map[string]int)
+	r.byName = make(map[string]int)
+	abbrs := make(map[string]bool)
+	for i, c := range r.Characters {
+		if c.Key == "" || c.Name == "" || c.Abbr == "" || c.Element == "" || c.Weapon == "" {
+			return errors.Errorf("character %v needs a key, name, abbr, element and weapon", i+1)
+		}
+		if c.Key != strings.ToLower(c.Key) {
+			return errors.Errorf("key %q must be lower case", c.Key)
+		}
+		for _, k := range append([]string{c.Key}, c.Aliases...) {
+			if _, ok := r.byKey[k]; ok {
+				return errors.Errorf("key %q is used twice", k)
+			}
+			r.byKey[k] = i
+		}
+		if _, ok := r.byName[c.Name]; ok {
+			return errors.Errorf("name %q is used twice", c.Name)
+		}
+		r.byName[c.Name] = i
+		if abbrs[c.Abbr] {
+			return errors.Errorf("abbr %q is used twice", c.Abbr)
+		}
+		abbrs[c.Abbr] = true
+	}
+	return nil
+}
+
+// Lookup finds a character by gcsim key or alias
+func (r *Registry) Lookup(key string) (Character, error) {
+	i, ok := r.byKey[strings.ToLower(key)]
+	if !ok {
+		return Character{}, errors.Errorf("unknown character %q", key)
+	}
+	return r.Characters[i], nil
+}
+
+// ByName finds a character by display name
+func (r *Registry) ByName(name string) (Character, error) {
+	i, ok := r.byName[name]
+	if !ok {
+		return Character{}, errors.Errorf("no character named %q", name)
+	}
+	return r.Characters[i], nil
+}
+
+// Add puts c in the registry, failing if anything about it clashes with an
+// existing character
+func (r *Registry) Add(c Character) error {
+	c.Key = strings.ToLower(c.Key)
+	r.Characters = append(r.Characters, c)
+	err := r.index()
+	if err != nil {
+		r.Characters = r.Characters[:len(r.Characters)-1]
+		r.index()
+		return err
+	}
+	return nil
+}
+
+const registryHeader = `# Characters the db knows about. key is the name gcsim reports in results,
+# name is the display name and db folder, abbr makes up team file names.
+# Add new characters with the add-char command or by hand; keys, names and
+# abbrs must all be unique.
+`
+
+// Save writes the registry to path
+func (r *Registry) Save(path string) error {
+	out, err := yaml.Marshal(r)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	out = append([]byte(registryHeader), out...)
+	return errors.Wrap(os.WriteFile(path, out, 0755), "")
+}
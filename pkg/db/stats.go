@@ -0,0 +1,33 @@
+package db
+
+import (
+	"math"
+
+	"github.com/genshinsim/gcsimdb/pkg/sim"
+)
+
+// Stats sums up how a value was spread over the iterations of a sim
+type Stats struct {
+	Min        float64 `yaml:"min" json:"min"`
+	Max        float64 `yaml:"max" json:"max"`
+	Mean       float64 `yaml:"mean" json:"mean"`
+	SD         float64 `yaml:"sd" json:"sd"`
+	Iterations int     `yaml:"iterations" json:"iterations"`
+	//standard error of the mean, SD/sqrt(Iterations)
+	SE float64 `yaml:"se" json:"se"`
+}
+
+// NewStats builds Stats from a gcsim result over n iterations
+func NewStats(f sim.FloatResult, n int) Stats {
+	s := Stats{Min: f.Min, Max: f.Max, Mean: f.Mean, SD: f.SD, Iterations: n}
+	if n > 0 {
+		s.SE = f.SD / math.Sqrt(float64(n))
+	}
+	return s
+}
+
+// Known reports whether s was recorded; teams rerun before stats were kept
+// only have their mean dps
+func (s Stats) Known() bool {
+	return s.Iterations > 0
+}
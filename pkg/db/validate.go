@@ -0,0 +1,249 @@
+package db
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Problem is something wrong with a single db file
+type Problem struct {
+	Path string
+	Msg  string
+}
+
+func (p Problem) String() string {
+	return p.Path + ": " + p.Msg
+}
+
+var reConfigChar = regexp.MustCompile(`(?m)^\s*(\w+)\s+char\b`)
+
+// ConfigChars returns the gcsim keys of the characters set up in cfg. Aliases
+// are resolved through the character registry; unknown names are kept as is.
+func ConfigChars(cfg string) []string {
+	var keys []string
+	for _, m := range reConfigChar.FindAllStringSubmatch(cfg, -1) {
+		key := strings.ToLower(m[1])
+		if c, err := Characters().Lookup(key); err == nil {
+			key = c.Key
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+var reConfigWeapon = regexp.MustCompile(`(?m)^\s*(\w+)\s+add\s+weapon\s*=\s*"?(\w+)"?`)
+
+// WeaponSetup sums up which weapon each character in cfg uses, e.g.
+// "bennett:aquilafavonia fischl:thestringless". Variants of a team with the
+// same setup are the same variant.
+func WeaponSetup(cfg string) string {
+	var setup []string
+	for _, m := range reConfigWeapon.FindAllStringSubmatch(cfg, -1) {
+		key := strings.ToLower(m[1])
+		if c, err := Characters().Lookup(key); err == nil {
+			key = c.Key
+		}
+		setup = append(setup, key+":"+strings.ToLower(m[2]))
+	}
+	sort.Strings(setup)
+	return strings.Join(setup, " ")
+}
+
+// ValidateDir checks every file under dir, returning the problems found and the number of files checked
+func ValidateDir(dir string) ([]Problem, int, error) {
+	var problems []Problem
+	n := 0
+	err := filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+		//do nothing if is directory
+		if info.IsDir() {
+			return nil
+		}
+		n++
+		problems = append(problems, ValidateFile(path)...)
+		return nil
+	})
+	if err != nil {
+		return problems, n, err
+	}
+
+	//team names have to be unique across folders for ingest to find them
+	_, err = BuildIndex(dir)
+	var dup *DuplicateError
+	if errors.As(err, &dup) {
+		var names []string
+		for name := range dup.Paths {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			paths := dup.Paths[name]
+			for _, path := range paths {
+				problems = append(problems, Problem{Path: path, Msg: fmt.Sprintf("%v.yaml is also in %v", name, otherPaths(paths, path))})
+			}
+		}
+		err = nil
+	}
+	return problems, n, err
+}
+
+func otherPaths(paths []string, path string) string {
+	var others []string
+	for _, p := range paths {
+		if p != path {
+			others = append(others, p)
+		}
+	}
+	return strings.Join(others, " and ")
+}
+
+// ValidateFile checks a single team file against the db schema:
+//   - it must be valid yaml with only the known keys and the right types
+//   - config is required and must set up 1 to 4 known characters
+//   - team, once filled in by a rerun, must match the characters in config
+//   - the file name must be the one GetName gives those characters, or a
+//     variant of it
+//   - the folder must be the main dps's, the character doing the most damage
+//     to the first target; files rerun before the damage breakdown was kept
+//     only need it to belong to one of the team
+func ValidateFile(path string) []Problem {
+	var problems []Problem
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, Problem{Path: path, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	if filepath.Ext(path) != ".yaml" {
+		report("not a .yaml file")
+		return problems
+	}
+	file, err := os.ReadFile(path)
+	if err != nil {
+		report("can't read file: %v", err)
+		return problems
+	}
+	var p Pack
+	err = yaml.UnmarshalStrict(file, &p)
+	if err != nil {
+		report("%v", err)
+		return problems
+	}
+
+	if strings.TrimSpace(p.Config) == "" {
+		report("config is missing")
+		return problems
+	}
+	keys := ConfigChars(p.Config)
+	if len(keys) < 1 || len(keys) > 4 {
+		report("config sets up %v characters, want 1 to 4", len(keys))
+	}
+	for _, k := range keys {
+		if _, err := Characters().Lookup(k); err != nil {
+			report("%v in config", err)
+			return problems
+		}
+	}
+	if p.DPS < 0 || p.Duration < 0 || p.NumTarget < 0 {
+		report("dps, duration and target_count can't be negative")
+	}
+	if s := p.DPSStats; s.Known() && (s.Min > s.Mean || s.Mean > s.Max || s.SD < 0 || s.SE < 0) {
+		report("dps_stats are inconsistent: min %v, mean %v, max %v, sd %v, se %v", s.Min, s.Mean, s.Max, s.SD, s.SE)
+	}
+
+	//team is only filled in once the team has been rerun
+	if len(p.Team) > 0 {
+		var team []string
+		for _, c := range p.Team {
+			team = append(team, c.Name)
+		}
+		if !sameChars(team, keys) {
+			report("team %v doesn't match the characters in config %v", team, keys)
+		}
+		checkDPSShares(p.Team, report)
+	}
+
+	if len(keys) > 4 {
+		return problems
+	}
+	name, err := nameForKeys(keys)
+	if err != nil {
+		report("%v", err)
+		return problems
+	}
+	//variants of a team share its name, with -2, -3... on the end
+	if base, _ := SplitVariant(filepath.Base(path)); base != name {
+		report("file name should be %v.yaml", name)
+	}
+	folder := filepath.Base(filepath.Dir(path))
+	if strings.Contains(path, "DNU") {
+		return problems
+	}
+	if main, ok := p.MainDPS(); ok {
+		if f, _ := Folder(main.Name); f != folder {
+			report("folder %v should be %v, the team's main dps", folder, f)
+		}
+		return problems
+	}
+	inTeam := false
+	for _, k := range keys {
+		f, _ := Folder(k)
+		inTeam = inTeam || f == folder
+	}
+	if !inTeam {
+		report("folder %v isn't one of the team's characters", folder)
+	}
+
+	return problems
+}
+
+// checkDPSShares makes sure every character has its dps against the same
+// targets and that the shares of each target add up to the whole team.
+// Teams rerun before the breakdown was kept have none at all.
+func checkDPSShares(team []Char, report func(format string, args ...interface{})) {
+	targets := len(team[0].DPS)
+	sums := make([]float64, targets)
+	for _, c := range team {
+		if len(c.DPS) != targets || len(c.DPSPct) != targets {
+			report("%v has dps for %v targets and dps_pct for %v, want %v", c.Name, len(c.DPS), len(c.DPSPct), targets)
+			return
+		}
+		for t, pct := range c.DPSPct {
+			if c.DPS[t] < 0 || pct < 0 {
+				report("%v has negative dps against target %v", c.Name, t+1)
+			}
+			sums[t] += pct
+		}
+	}
+	for t, sum := range sums {
+		//a team doing no damage to a target has no shares of it
+		if sum != 0 && math.Abs(sum-100) > 0.1 {
+			report("dps_pct against target %v adds up to %.2f, want 100", t+1, sum)
+		}
+	}
+}
+
+func sameChars(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
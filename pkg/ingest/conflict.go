@@ -0,0 +1,130 @@
+package ingest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/genshinsim/gcsimdb/pkg/sim"
+	"github.com/pkg/errors"
+)
+
+// Policies for several submissions of the same team in one batch
+const (
+	//ingest none of them and list them so the operator can pick
+	PolicyReport = "report"
+	//keep the one with the highest mean dps
+	PolicyHighestDPS = "highest-dps"
+	//keep the one furthest down the input file
+	PolicyLatest = "latest"
+	//keep them all, the first in the variant they were for and the rest as new variants
+	PolicyVariants = "variants"
+)
+
+// Policies lists every conflict policy, for flag help
+var Policies = []string{PolicyReport, PolicyHighestDPS, PolicyLatest, PolicyVariants}
+
+// candidate is a fetched submission waiting to be written
+type candidate struct {
+	sub  Submission
+	data sim.Result
+	//team file name from db.GetName
+	name string
+	//file name of the variant it goes to; empty until a new variant is handed out
+	target string
+	//which submissions compete for the same variant
+	key string
+	//config an earlier batch already put in target, still waiting for a rerun
+	pending bool
+}
+
+// describe names the variant c goes to, for messages
+func (c candidate) describe() string {
+	if c.target == "" {
+		return "a new variant of " + c.name
+	}
+	return c.target + ".yaml"
+}
+
+// groupByTeam groups cands by the team variant they go to, keeping the order
+// variants first appear in
+func groupByTeam(cands []candidate) [][]candidate {
+	var groups [][]candidate
+	index := make(map[string]int)
+	for _, c := range cands {
+		i, ok := index[c.key]
+		if !ok {
+			i = len(groups)
+			index[c.key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], c)
+	}
+	return groups
+}
+
+// checkPolicy makes sure policy is one of Policies
+func checkPolicy(policy string) error {
+	for _, p := range Policies {
+		if p == policy {
+			return nil
+		}
+	}
+	return errors.Errorf("unknown conflict policy %q, want one of %v", policy, strings.Join(Policies, ", "))
+}
+
+// resolve applies policy to a group of submissions for the same team,
+// returning the ones to keep and the ones to drop
+func resolve(group []candidate, policy string) (keep, drop []candidate) {
+	if len(group) == 1 {
+		return group, nil
+	}
+	switch policy {
+	case PolicyReport:
+		return nil, group
+	case PolicyLatest:
+		last := len(group) - 1
+		return group[last:], group[:last]
+	case PolicyHighestDPS:
+		best := 0
+		for i, c := range group {
+			//a pending config was never run, so any result beats it
+			if c.data.DPS.Mean > group[best].data.DPS.Mean || group[best].pending {
+				best = i
+			}
+		}
+		for i, c := range group {
+			if i != best {
+				drop = append(drop, c)
+			}
+		}
+		return group[best : best+1], drop
+	}
+	return group, nil
+}
+
+// conflictReport describes a group of submissions for the same team and what
+// was done with them
+func conflictReport(group, keep []candidate, policy string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\tconflict: %v submissions for %v (policy %v)\n", len(group), group[0].describe(), policy)
+	for _, c := range group {
+		verdict := "dropped"
+		for _, k := range keep {
+			if k.sub.Line == c.sub.Line {
+				verdict = "kept"
+			}
+		}
+		if policy == PolicyReport {
+			verdict = "not ingested"
+		}
+		if c.pending {
+			if verdict != "dropped" {
+				verdict = "left as is"
+			}
+			fmt.Fprintf(&b, "\t\tpending: %v by %v, not rerun yet: %v\n", c.sub.URL, c.sub.Author, verdict)
+			continue
+		}
+		fmt.Fprintf(&b, "\t\tline %v: %v by %v, %.0f dps: %v\n", c.sub.Line, c.sub.URL, c.sub.Author, c.data.DPS.Mean, verdict)
+	}
+	return b.String()
+}
@@ -0,0 +1,132 @@
+package ingest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/genshinsim/gcsimdb/pkg/db"
+	"github.com/genshinsim/gcsimdb/pkg/sim"
+)
+
+// cand is a submission on line for target with the given dps
+func cand(line int, target string, dps float64) candidate {
+	return candidate{
+		sub:    Submission{URL: share, Line: line},
+		data:   sim.Result{DPS: sim.FloatResult{Mean: dps}},
+		name:   "team",
+		target: target,
+		key:    target,
+	}
+}
+
+// lines returns the input lines of cands, 0 for a pending config
+func lines(cands []candidate) []int {
+	var out []int
+	for _, c := range cands {
+		out = append(out, c.sub.Line)
+	}
+	return out
+}
+
+func TestResolve(t *testing.T) {
+	pending := cand(0, "team", 0)
+	pending.pending = true
+	group := []candidate{cand(1, "team", 200), cand(2, "team", 300), cand(3, "team", 100)}
+	cases := []struct {
+		name   string
+		group  []candidate
+		policy string
+		keep   []int
+		drop   []int
+	}{
+		{"single", group[:1], PolicyReport, []int{1}, nil},
+		{"report", group, PolicyReport, nil, []int{1, 2, 3}},
+		{"latest", group, PolicyLatest, []int{3}, []int{1, 2}},
+		{"highest dps", group, PolicyHighestDPS, []int{2}, []int{1, 3}},
+		{"variants", group, PolicyVariants, []int{1, 2, 3}, nil},
+		//a config from an earlier batch competes with the new ones
+		{"pending report", []candidate{pending, group[0]}, PolicyReport, nil, []int{0, 1}},
+		{"pending latest", []candidate{pending, group[0]}, PolicyLatest, []int{1}, []int{0}},
+		{"pending highest dps", []candidate{pending, cand(1, "team", 0)}, PolicyHighestDPS, []int{1}, []int{0}},
+		{"pending variants", []candidate{pending, group[0]}, PolicyVariants, []int{0, 1}, nil},
+	}
+	for _, c := range cases {
+		keep, drop := resolve(c.group, c.policy)
+		if !reflect.DeepEqual(lines(keep), c.keep) || !reflect.DeepEqual(lines(drop), c.drop) {
+			t.Errorf("%v: kept %v, dropped %v; want %v, %v", c.name, lines(keep), lines(drop), c.keep, c.drop)
+		}
+	}
+}
+
+func TestGroupByTeamAndNewVariant(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"team.yaml", "team-3.yaml"} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte("config: x;\n"), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	index, err := db.BuildIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := &Ingester{Dir: dir, index: index}
+
+	newA := cand(3, "", 100)
+	newA.key = "team|new|a"
+	newB := cand(4, "", 100)
+	newB.key = "team|new|b"
+	groups := groupByTeam([]candidate{cand(1, "team", 100), newA, cand(2, "team-3", 100), cand(5, "team", 100), newB})
+	var got [][]int
+	for _, g := range groups {
+		got = append(got, lines(g))
+	}
+	want := [][]int{{1, 5}, {3}, {2}, {4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groups = %v, want %v", got, want)
+	}
+
+	//variants 1 and 3 have files, so new ones fill the gap first
+	var names []string
+	for i := 0; i < 3; i++ {
+		names = append(names, in.newVariant("team"))
+	}
+	if !reflect.DeepEqual(names, []string{"team-2", "team-4", "team-5"}) {
+		t.Errorf("new variants = %v", names)
+	}
+	if got := in.newVariant("other"); got != "other" {
+		t.Errorf("first variant of a new team = %q, want other", got)
+	}
+}
+
+func TestPendingCandidate(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"team.yaml":  "config: old;\nhash: v1\n",
+		"other.yaml": "config: new;\nhistory:\n  - config: new;\n    submitter: B#5678\n",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	index, err := db.BuildIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := &Ingester{Dir: dir, index: index}
+
+	if _, ok := in.pendingCandidate(cand(1, "team", 100)); ok {
+		t.Error("a file that was rerun has no pending config")
+	}
+	if _, ok := in.pendingCandidate(cand(1, "", 100)); ok {
+		t.Error("a new variant has no pending config")
+	}
+	p, ok := in.pendingCandidate(cand(1, "other", 100))
+	if !ok || !p.pending || p.data.Config != "new;" || p.sub.Author != "B#5678" || p.key != "other" {
+		t.Errorf("got %+v, %v; want the pending config of other.yaml", p, ok)
+	}
+}
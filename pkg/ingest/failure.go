@@ -0,0 +1,30 @@
+package ingest
+
+import (
+	"fmt"
+)
+
+// SubmissionError records why a single submission could not be ingested
+type SubmissionError struct {
+	Submission Submission
+	//step of ingesting that failed: fetch, name, read or write
+	Stage string
+	Err   error
+}
+
+func (e *SubmissionError) Error() string {
+	return fmt.Sprintf("line %v (%v): %v: %v", e.Submission.Line, e.Submission.URL, e.Stage, e.Err)
+}
+
+func (e *SubmissionError) Cause() error { return e.Err }
+
+// FailedError is returned by UpdateData when some submissions failed; the
+// rest were still ingested
+type FailedError struct {
+	Failed []*SubmissionError
+	Total  int
+}
+
+func (e *FailedError) Error() string {
+	return fmt.Sprintf("%v of %v submissions failed", len(e.Failed), e.Total)
+}
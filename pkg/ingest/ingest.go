@@ -0,0 +1,285 @@
+// Package ingest adds submitted teams to the db.
+package ingest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/genshinsim/gcsimdb/pkg/db"
+	"github.com/genshinsim/gcsimdb/pkg/sim"
+	"github.com/genshinsim/gcsimdb/pkg/viewer"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Ingester adds new and updated teams from an input file of submissions to
+// the db
+type Ingester struct {
+	Dir    string
+	Viewer *viewer.Client
+	//fetch and parse submissions but only report the files that would change
+	DryRun bool
+	//what to do with several submissions of the same team; one of Policies
+	Policy string
+	//send submissions that don't name a variant to the variant with the same
+	//weapons, or a new one if there is none, instead of the existing team
+	MatchWeapons bool
+	//notes on what happened to each submission, for the end of run summary
+	skipped string
+	//files created or updated (or that would be, in a dry run)
+	touched []string
+	failed  []*SubmissionError
+	//every team file in Dir, built on first use
+	index *db.Index
+	//new variants handed out this batch, by file name
+	reserved map[string]bool
+}
+
+// Summary returns the notes collected while ingesting
+func (in *Ingester) Summary() string {
+	return in.skipped
+}
+
+// Failed returns the submissions that couldn't be ingested
+func (in *Ingester) Failed() []*SubmissionError {
+	return in.failed
+}
+
+// Touched returns the files ingesting created or updated, or would have in a dry run
+func (in *Ingester) Touched() []string {
+	return in.touched
+}
+
+func (in *Ingester) save(d db.Pack, verb string) error {
+	//new files go in the index so later submissions in the batch find them,
+	//even in a dry run where they are never written
+	err := in.index.Add(d.Path)
+	if err != nil {
+		return err
+	}
+	if in.DryRun {
+		fmt.Printf("\t[dry-run] would %v %v\n", verb, d.Path)
+		in.touched = append(in.touched, d.Path)
+		return nil
+	}
+	err = db.Save([]db.Pack{d}, false)
+	if err != nil {
+		return err
+	}
+	in.touched = append(in.touched, d.Path)
+	return nil
+}
+
+// UpdateData ingests every submission in inputfile; see ReadSubmissions for
+// the formats
+func (in *Ingester) UpdateData(inputfile string) error {
+	return in.Add(ReadSubmissions(inputfile))
+}
+
+// Add ingests subs, taking the ignored lines and error of reading them so it
+// can be fed straight from ReadSubmissions or ReadCSV. A submission that fails
+// is recorded and skipped, and a *FailedError listing them is returned once
+// the rest are done.
+func (in *Ingester) Add(subs []Submission, ignored []Ignored, err error) error {
+	for _, ig := range ignored {
+		fmt.Printf("\tignored %v\n", ig)
+		in.skipped += fmt.Sprintf("\tignored %v\n", ig)
+	}
+	if err != nil {
+		return err
+	}
+
+	if in.Policy == "" {
+		in.Policy = PolicyReport
+	}
+	err = checkPolicy(in.Policy)
+	if err != nil {
+		return err
+	}
+	//a team in two folders would make updates land in either one
+	in.index, err = db.BuildIndex(in.Dir)
+	if err != nil {
+		return err
+	}
+
+	//fetch everything first so submissions of the same team can be compared
+	var cands []candidate
+	for _, sub := range subs {
+		c, err := in.fetch(sub)
+		if err != nil {
+			in.fail(err.(*SubmissionError))
+			continue
+		}
+		c.target, c.key, err = in.pickVariant(c)
+		if err != nil {
+			in.fail(&SubmissionError{Submission: sub, Stage: "variant", Err: err})
+			continue
+		}
+		cands = append(cands, c)
+	}
+
+	for _, group := range groupByTeam(cands) {
+		//a config from an earlier batch that hasn't been rerun competes too
+		if p, ok := in.pendingCandidate(group[0]); ok {
+			group = append([]candidate{p}, group...)
+		}
+		keep, drop := resolve(group, in.Policy)
+		if len(group) > 1 {
+			report := conflictReport(group, keep, in.Policy)
+			fmt.Print(report)
+			in.skipped += report
+		}
+		if in.Policy == PolicyReport {
+			for _, c := range drop {
+				if c.pending {
+					continue
+				}
+				in.fail(&SubmissionError{Submission: c.sub, Stage: "conflict", Err: errors.Errorf("%v other submissions for %v", len(group)-1, c.describe())})
+			}
+		}
+		for i, c := range keep {
+			if c.pending {
+				continue
+			}
+			//only PolicyVariants keeps more than one
+			if c.target == "" || i > 0 {
+				c.target = in.newVariant(c.name)
+			}
+			err := in.ingest(c)
+			if err != nil {
+				se, ok := err.(*SubmissionError)
+				if !ok {
+					se = &SubmissionError{Submission: c.sub, Stage: "write", Err: err}
+				}
+				in.fail(se)
+			}
+		}
+	}
+
+	if len(in.failed) > 0 {
+		for _, se := range in.failed {
+			in.skipped += fmt.Sprintf("\tFAILED: %v\n", se)
+		}
+		return &FailedError{Failed: in.failed, Total: len(subs)}
+	}
+	return nil
+}
+
+func (in *Ingester) fail(se *SubmissionError) {
+	fmt.Printf("\tFailed %v\n", se)
+	in.failed = append(in.failed, se)
+}
+
+// fetch reads the result of sub and works out which team it is
+func (in *Ingester) fetch(sub Submission) (candidate, error) {
+	data, err := in.Viewer.Fetch(sub.URL)
+	if err != nil {
+		return candidate{}, &SubmissionError{Submission: sub, Stage: "fetch", Err: err}
+	}
+	name, err := db.GetName(data)
+	if err != nil {
+		return candidate{}, &SubmissionError{Submission: sub, Stage: "name", Err: err}
+	}
+	return candidate{sub: sub, data: data, name: name}, nil
+}
+
+// pendingCandidate returns the config waiting for a rerun in the file c goes
+// to, if there is one, as a submission competing with c. It is never rerun so
+// its dps is unknown and it loses to any submission under PolicyHighestDPS.
+func (in *Ingester) pendingCandidate(c candidate) (candidate, bool) {
+	if c.target == "" {
+		return candidate{}, false
+	}
+	path, ok := in.index.Lookup(c.target)
+	if !ok {
+		return candidate{}, false
+	}
+	file, err := os.ReadFile(path)
+	if err != nil {
+		//updateFile reports it
+		return candidate{}, false
+	}
+	var d db.Pack
+	if yaml.Unmarshal(file, &d) != nil || d.Hash != "" {
+		return candidate{}, false
+	}
+	submitter := d.Author.String()
+	if n := len(d.History); n > 0 && d.History[n-1].Pending() {
+		submitter = d.History[n-1].Submitter
+	}
+	return candidate{
+		sub:     Submission{URL: path, Author: submitter, Description: d.Description},
+		data:    sim.Result{Config: d.Config},
+		name:    c.name,
+		target:  c.target,
+		key:     c.key,
+		pending: true,
+	}, true
+}
+
+// ingest adds or updates the team variant picked for a single submission
+func (in *Ingester) ingest(c candidate) error {
+	if path, ok := in.index.Lookup(c.target); ok {
+		return in.updateFile(path, c.data, c.sub)
+	}
+	return in.makeFile(c.target+".yaml", c.data, c.sub)
+}
+
+func (in *Ingester) updateFile(path string, data sim.Result, sub Submission) error {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return &SubmissionError{Submission: sub, Stage: "read", Err: err}
+	}
+	var d db.Pack
+	err = yaml.Unmarshal(file, &d)
+	if err != nil {
+		return &SubmissionError{Submission: sub, Stage: "read", Err: errors.Wrap(err, path)}
+	}
+	if d.Hash == "" { //no hash means an earlier ingest updated it and it hasn't been rerun yet
+		fmt.Printf("\tupdating %v again before it was rerun\n", sub.URL)
+		in.skipped += fmt.Sprintf("\tupdating %v again before it was rerun\n", sub.URL)
+	} else {
+		fmt.Printf("\tupdating %v\n", sub.URL)
+		in.skipped += fmt.Sprintf("\tupdating %v\n", sub.URL)
+	}
+
+	d.Path = path
+	d.Submit(data.Config, sub.Author)
+	//fmt.Prtitf("%v", sub.Description)
+	if sub.Description != "" { //leave the old desc if new one is empty
+		d.Description = sub.Description
+	}
+	for _, c := range credits(sub, db.RoleUpdate) {
+		d.Author.Credit(c)
+	}
+
+	return in.save(d, "update")
+}
+
+func (in *Ingester) makeFile(filename string, data sim.Result, sub Submission) error {
+	//the folder of the main dps, checked against the characters the result has
+	path, err := db.TeamPath(in.Dir, data)
+	if err != nil {
+		return &SubmissionError{Submission: sub, Stage: "name", Err: err}
+	}
+	var d db.Pack
+	d.Path = filepath.Join(filepath.Dir(path), filename)
+	d.Submit(data.Config, sub.Author)
+	d.Description = sub.Description
+	d.Author = credits(sub, db.RoleOriginal)
+
+	return in.save(d, "create")
+}
+
+// credits are the contributors named in the author of sub, credited with role
+// from today
+func credits(sub Submission, role string) db.Authors {
+	authors := db.ParseAuthors(sub.Author)
+	for i := range authors {
+		authors[i].Role = role
+		authors[i].Since = time.Now().Format("2006-01-02")
+	}
+	return authors
+}
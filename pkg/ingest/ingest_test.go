@@ -0,0 +1,31 @@
+package ingest
+
+import (
+	"testing"
+
+	"github.com/genshinsim/gcsimdb/pkg/db"
+	"github.com/genshinsim/gcsimdb/pkg/sim"
+)
+
+func TestMakeFileMismatchedResult(t *testing.T) {
+	dir := t.TempDir()
+	index, err := db.BuildIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := &Ingester{Dir: dir, index: index}
+	//the second breakdown entry has the most damage but no character to go with it
+	data := sim.Result{
+		Config:     "bennett char lvl=90/90;",
+		Characters: []sim.CharDetail{{Name: "bennett"}},
+		CharDPS:    []sim.CharTargetDPS{{"1": {Mean: 100}}, {"1": {Mean: 900}}},
+	}
+	err = in.makeFile("bennett.yaml", data, Submission{URL: share, Line: 1})
+	se, ok := err.(*SubmissionError)
+	if !ok || se.Stage != "name" {
+		t.Errorf("err = %v, want a naming SubmissionError", err)
+	}
+	if len(in.Touched()) > 0 {
+		t.Errorf("wrote %v", in.Touched())
+	}
+}
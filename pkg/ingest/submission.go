@@ -0,0 +1,371 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/genshinsim/gcsimdb/pkg/viewer"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Submission is a single team sent in for the db
+type Submission struct {
+	URL         string `yaml:"url" json:"url"`
+	Author      string `yaml:"author" json:"author"`
+	Description string `yaml:"description" json:"description"`
+	//which variant of the team this is: empty for the existing team (or the
+	//one with the same weapons, see Ingester.MatchWeapons), VariantNew, or a
+	//variant number
+	Variant string `yaml:"variant,omitempty" json:"variant,omitempty"`
+	//where the submission came from in the input file, for messages
+	Line int `yaml:"-" json:"-"`
+}
+
+// Ignored is a line of the input file that wasn't a submission
+type Ignored struct {
+	Line   int
+	Text   string
+	Reason string
+}
+
+func (ig Ignored) String() string {
+	return fmt.Sprintf("line %v: %v: %q", ig.Line, ig.Reason, ig.Text)
+}
+
+// ParseError is a problem with one line of the input file
+type ParseError struct {
+	Line int
+	Msg  string
+}
+
+func (e ParseError) Error() string {
+	if e.Line == 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("line %v: %v", e.Line, e.Msg)
+}
+
+// ParseErrors is every problem found in an input file
+type ParseErrors []ParseError
+
+func (e ParseErrors) Error() string {
+	var msgs []string
+	for _, pe := range e {
+		msgs = append(msgs, pe.Error())
+	}
+	return fmt.Sprintf("%v bad lines in input:\n\t%v", len(e), strings.Join(msgs, "\n\t"))
+}
+
+// ReadSubmissions reads the input file at path. The format is picked by
+// extension: .yaml/.yml, .json and .csv are structured, anything else is read
+// as url~author~description lines. Nothing is returned if any line is bad, so
+// a typo can't half ingest a file.
+func ReadSubmissions(path string) ([]Submission, []Ignored, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "")
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return checkSubmissions(parseYAML(b))
+	case ".json":
+		return checkSubmissions(parseJSON(b))
+	case ".csv":
+		return ReadCSV(path, DefaultCSVColumns)
+	}
+	return ParseLines(b)
+}
+
+// ParseLines reads url~author~description lines.
+//
+// Blank lines and lines starting with # are skipped. Lines without a ~, or
+// whose url viewer.Resolve doesn't understand, are ignored and reported. A field can
+// contain ~ if it is escaped as \~, or if the whole field is wrapped in double
+// quotes, with "" standing for a quote inside; \\ and \" stand for \ and ".
+// Any other \ or quote is kept as written. Old lines with unescaped ~ in the
+// author still work: the first field is the url, the last the description and
+// everything between is joined back into the author.
+func ParseLines(b []byte) ([]Submission, []Ignored, error) {
+	var subs []Submission
+	var ignored []Ignored
+	var bad ParseErrors
+
+	for i, line := range strings.Split(string(b), "\n") {
+		n := i + 1
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if !strings.Contains(line, "~") {
+			ignored = append(ignored, Ignored{Line: n, Text: line, Reason: "no ~ separated author and description"})
+			continue
+		}
+		fields := splitLine(line)
+		switch {
+		case len(fields) < 3:
+			bad = append(bad, ParseError{Line: n, Msg: fmt.Sprintf("want url~author~description, got %v fields", len(fields))})
+			continue
+		case len(fields) > 3:
+			//someone has ~ in their name
+			fields = []string{fields[0], strings.Join(fields[1:len(fields)-1], "~"), fields[len(fields)-1]}
+		}
+		s := Submission{URL: strings.TrimSpace(fields[0]), Author: fields[1], Description: fields[2], Line: n}
+		if _, err := viewer.Resolve(s.URL); err != nil {
+			ignored = append(ignored, Ignored{Line: n, Text: line, Reason: err.Error()})
+			continue
+		}
+		subs = append(subs, s)
+	}
+
+	if len(bad) > 0 {
+		return nil, ignored, bad
+	}
+	return subs, ignored, nil
+}
+
+// splitLine splits line on ~. A field is only read as quoted when it is
+// wrapped in quotes as a whole, and \ only escapes ~, \ and "; anything else
+// is kept as written so old lines come through unchanged.
+func splitLine(line string) []string {
+	var fields []string
+	for i := 0; ; {
+		var field string
+		if quoted, next, ok := quotedField(line, i); ok {
+			field, i = quoted, next
+		} else {
+			field, i = plainField(line, i)
+		}
+		fields = append(fields, field)
+		if i >= len(line) {
+			return fields
+		}
+		//skip the ~
+		i++
+	}
+}
+
+// quotedField reads a field wrapped in quotes starting at i, with "" standing
+// for a quote inside. ok is false unless the closing quote ends the field.
+func quotedField(line string, i int) (string, int, bool) {
+	if i >= len(line) || line[i] != '"' {
+		return "", i, false
+	}
+	var field strings.Builder
+	for j := i + 1; j < len(line); j++ {
+		if line[j] != '"' {
+			field.WriteByte(line[j])
+			continue
+		}
+		if j+1 < len(line) && line[j+1] == '"' {
+			field.WriteByte('"')
+			j++
+			continue
+		}
+		if j+1 == len(line) || line[j+1] == '~' {
+			return field.String(), j + 1, true
+		}
+		return "", i, false
+	}
+	return "", i, false
+}
+
+// plainField reads a field up to the next unescaped ~
+func plainField(line string, i int) (string, int) {
+	var field strings.Builder
+	for ; i < len(line) && line[i] != '~'; i++ {
+		if line[i] == '\\' && i+1 < len(line) && strings.IndexByte(`~\"`, line[i+1]) >= 0 {
+			i++
+		}
+		field.WriteByte(line[i])
+	}
+	return field.String(), i
+}
+
+func parseYAML(b []byte) ([]Submission, error) {
+	var subs []Submission
+	err := yaml.UnmarshalStrict(b, &subs)
+	if err != nil {
+		//yaml errors already say which line
+		return nil, ParseErrors{{Line: 0, Msg: err.Error()}}
+	}
+	//entries of a top level list start with a - in the first column
+	var starts []int
+	for i, line := range strings.Split(string(b), "\n") {
+		if line == "-" || strings.HasPrefix(line, "- ") {
+			starts = append(starts, i+1)
+		}
+	}
+	if len(starts) == len(subs) {
+		for i := range subs {
+			subs[i].Line = starts[i]
+		}
+	}
+	return subs, nil
+}
+
+func parseJSON(b []byte) ([]Submission, error) {
+	var subs []Submission
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	fail := func(err error) ParseErrors {
+		offset := dec.InputOffset()
+		var syntax *json.SyntaxError
+		var typ *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntax):
+			offset = syntax.Offset
+		case errors.As(err, &typ):
+			offset = typ.Offset
+		}
+		return ParseErrors{{Line: lineAt(b, offset), Msg: err.Error()}}
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fail(err)
+	}
+	if tok != json.Delim('[') {
+		return nil, ParseErrors{{Line: 1, Msg: "want a list of submissions"}}
+	}
+	for dec.More() {
+		var s Submission
+		//skip to the start of the entry so its line is reported, not the end of the last one
+		start := dec.InputOffset()
+		for start < int64(len(b)) && strings.ContainsRune(" \t\r\n,", rune(b[start])) {
+			start++
+		}
+		err = dec.Decode(&s)
+		if err != nil {
+			//offsets inside an entry aren't from the start of the file
+			return nil, ParseErrors{{Line: lineAt(b, start), Msg: err.Error()}}
+		}
+		s.Line = lineAt(b, start)
+		subs = append(subs, s)
+	}
+	_, err = dec.Token()
+	if err != nil {
+		return nil, fail(err)
+	}
+	return subs, nil
+}
+
+// lineAt is the line number of byte offset in b
+func lineAt(b []byte, offset int64) int {
+	if offset > int64(len(b)) {
+		offset = int64(len(b))
+	}
+	return bytes.Count(b[:offset], []byte("\n")) + 1
+}
+
+// CSVColumns are the header names of the columns a csv of submissions keeps
+// each field in. Headers are matched ignoring case and surrounding spaces.
+type CSVColumns struct {
+	URL         string
+	Author      string
+	Description string
+	Variant     string
+}
+
+// DefaultCSVColumns is what a .csv input file is read with
+var DefaultCSVColumns = CSVColumns{URL: "url", Author: "author", Description: "description", Variant: "variant"}
+
+// ReadCSV reads a csv of submissions from path, taking each field from the
+// column named in cols. Rows without a url are ignored and reported.
+func ReadCSV(path string, cols CSVColumns) ([]Submission, []Ignored, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "")
+	}
+	subs, ignored, err := parseCSV(b, cols)
+	subs, _, err = checkSubmissions(subs, err)
+	if err != nil {
+		return nil, ignored, err
+	}
+	return subs, ignored, nil
+}
+
+func parseCSV(b []byte, cols CSVColumns) ([]Submission, []Ignored, error) {
+	r := csv.NewReader(bytes.NewReader(b))
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, nil, ParseErrors{{Line: 1, Msg: err.Error()}}
+	}
+	index := func(name string) int {
+		for i, h := range header {
+			if name != "" && strings.EqualFold(strings.TrimSpace(h), strings.TrimSpace(name)) {
+				return i
+			}
+		}
+		return -1
+	}
+	urlCol, authorCol, descCol, variantCol := index(cols.URL), index(cols.Author), index(cols.Description), index(cols.Variant)
+	if urlCol < 0 {
+		return nil, nil, ParseErrors{{Line: 1, Msg: fmt.Sprintf("header has no %q column", cols.URL)}}
+	}
+
+	var subs []Submission
+	var ignored []Ignored
+	var bad ParseErrors
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				bad = append(bad, ParseError{Line: pe.Line, Msg: pe.Err.Error()})
+				continue
+			}
+			return nil, nil, errors.Wrap(err, "")
+		}
+		line, _ := r.FieldPos(0)
+		get := func(i int) string {
+			if i >= 0 && i < len(rec) {
+				return rec[i]
+			}
+			return ""
+		}
+		if strings.TrimSpace(get(urlCol)) == "" {
+			ignored = append(ignored, Ignored{Line: line, Text: strings.Join(rec, ","), Reason: "no url"})
+			continue
+		}
+		subs = append(subs, Submission{URL: get(urlCol), Author: get(authorCol), Description: get(descCol), Variant: get(variantCol), Line: line})
+	}
+	if len(bad) > 0 {
+		return nil, ignored, bad
+	}
+	return subs, ignored, nil
+}
+
+// checkSubmissions makes sure every structured submission has a url Resolve understands
+func checkSubmissions(subs []Submission, err error) ([]Submission, []Ignored, error) {
+	if err != nil {
+		return nil, nil, err
+	}
+	var bad ParseErrors
+	for i := range subs {
+		subs[i].URL = strings.TrimSpace(subs[i].URL)
+		if subs[i].URL == "" {
+			bad = append(bad, ParseError{Line: subs[i].Line, Msg: "url is missing"})
+		} else if _, err := viewer.Resolve(subs[i].URL); err != nil {
+			bad = append(bad, ParseError{Line: subs[i].Line, Msg: err.Error()})
+		}
+		if _, err := parseVariant(subs[i].Variant); err != nil {
+			bad = append(bad, ParseError{Line: subs[i].Line, Msg: err.Error()})
+		}
+	}
+	if len(bad) > 0 {
+		return nil, nil, bad
+	}
+	return subs, nil, nil
+}
@@ -0,0 +1,80 @@
+package ingest
+
+import (
+	"reflect"
+	"testing"
+)
+
+const share = "https://gcsim.app/viewer/share/abc123"
+
+func TestParseLines(t *testing.T) {
+	cases := []struct {
+		name string
+		line string
+		want Submission
+	}{
+		{"plain", share + "~Bob#1234~fast rotation", Submission{URL: share, Author: "Bob#1234", Description: "fast rotation"}},
+		{"crlf", share + "~Bob#1234~fast rotation\r", Submission{URL: share, Author: "Bob#1234", Description: "fast rotation"}},
+		{"empty description", share + "~Bob#1234~", Submission{URL: share, Author: "Bob#1234"}},
+		{"url with spaces", "  " + share + " ~Bob#1234~x", Submission{URL: share, Author: "Bob#1234", Description: "x"}},
+		{"escaped ~", share + `~Bo\~b#1234~a\~b`, Submission{URL: share, Author: "Bo~b#1234", Description: "a~b"}},
+		{"escaped \\ and quote", share + `~Bob#1234~a\\b \"c\"`, Submission{URL: share, Author: "Bob#1234", Description: `a\b "c"`}},
+		{"quoted field", share + `~"Bo~b#1234"~"say ""hi"""`, Submission{URL: share, Author: "Bo~b#1234", Description: `say "hi"`}},
+		//old lines must come through as they were written
+		{"leading quote", share + `~Bob#1234~"Fast" rotation`, Submission{URL: share, Author: "Bob#1234", Description: `"Fast" rotation`}},
+		{"unclosed quote", share + `~"Bob#1234~x`, Submission{URL: share, Author: `"Bob#1234`, Description: "x"}},
+		{"stray backslash", share + `~Bob#1234~¯\_(ツ)_/¯`, Submission{URL: share, Author: "Bob#1234", Description: `¯\_(ツ)_/¯`}},
+		{"trailing backslash", share + `~Bob#1234~a\`, Submission{URL: share, Author: "Bob#1234", Description: `a\`}},
+		{"unescaped ~ in author", share + "~lulu ~𝔂 ♡#4236~", Submission{URL: share, Author: "lulu ~𝔂 ♡#4236"}},
+		{"two ~ in author", share + "~a~b~c#1234~desc", Submission{URL: share, Author: "a~b~c#1234", Description: "desc"}},
+	}
+	for _, c := range cases {
+		subs, ignored, err := ParseLines([]byte(c.line))
+		if err != nil || len(ignored) > 0 {
+			t.Errorf("%v: err %v, ignored %v", c.name, err, ignored)
+			continue
+		}
+		c.want.Line = 1
+		if len(subs) != 1 || subs[0] != c.want {
+			t.Errorf("%v: got %+v, want %+v", c.name, subs, c.want)
+		}
+	}
+}
+
+func TestParseLinesSkipsAndIgnores(t *testing.T) {
+	input := "# comment\n\n" +
+		share + "~A#1111~one\n" +
+		"just some text\n" +
+		"https://example.com/x~B#2222~not a share link\n" +
+		share + "~C#3333~three\n"
+	subs, ignored, err := ParseLines([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []int
+	for _, s := range subs {
+		lines = append(lines, s.Line)
+	}
+	if !reflect.DeepEqual(lines, []int{3, 6}) {
+		t.Errorf("submissions from lines %v, want [3 6]", lines)
+	}
+	var ignoredLines []int
+	for _, ig := range ignored {
+		ignoredLines = append(ignoredLines, ig.Line)
+	}
+	if !reflect.DeepEqual(ignoredLines, []int{4, 5}) {
+		t.Errorf("ignored lines %v, want [4 5]", ignoredLines)
+	}
+}
+
+func TestParseLinesErrors(t *testing.T) {
+	input := share + "~A#1111~one\n" + share + "~only two\n"
+	subs, _, err := ParseLines([]byte(input))
+	if subs != nil {
+		t.Errorf("got submissions %+v alongside a bad line", subs)
+	}
+	pe, ok := err.(ParseErrors)
+	if !ok || len(pe) != 1 || pe[0].Line != 2 {
+		t.Errorf("err = %#v, want a ParseError for line 2", err)
+	}
+}
@@ -0,0 +1,91 @@
+package ingest
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/genshinsim/gcsimdb/pkg/db"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// VariantNew asks for a submission to become a new variant of its team
+const VariantNew = "new"
+
+// parseVariant checks the variant field of a submission: empty, VariantNew
+// or a variant number
+func parseVariant(v string) (int, error) {
+	v = strings.TrimSpace(v)
+	if v == "" || v == VariantNew {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, errors.Errorf("variant must be %q or a number from 1, got %q", VariantNew, v)
+	}
+	return n, nil
+}
+
+// pickVariant decides which variant of its team c goes to. A variant number
+// in the submission picks that variant and VariantNew asks for a new one.
+// Otherwise the team's existing variant is updated, or with MatchWeapons the
+// one with the same weapons, if any. A new variant only gets its number once
+// conflicts are resolved, so dropped submissions don't use any up; until then
+// target is empty and key groups it with others of the same new setup.
+func (in *Ingester) pickVariant(c candidate) (target string, key string, err error) {
+	want, err := parseVariant(c.sub.Variant)
+	if err != nil {
+		return "", "", err
+	}
+	if want > 0 {
+		target = db.VariantName(c.name, want)
+		return target, target, nil
+	}
+
+	setup := db.WeaponSetup(c.data.Config)
+	newKey := c.name + "|new|" + setup
+	if c.sub.Variant == VariantNew {
+		return "", newKey, nil
+	}
+
+	existing := in.index.Variants(c.name)
+	var nums []int
+	for n := range existing {
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+	if !in.MatchWeapons {
+		target = db.VariantName(c.name, 1)
+		if len(nums) > 0 {
+			target = db.VariantName(c.name, nums[0])
+		}
+		return target, target, nil
+	}
+	for _, n := range nums {
+		var p db.Pack
+		b, err := os.ReadFile(existing[n])
+		if err == nil && yaml.Unmarshal(b, &p) == nil && db.WeaponSetup(p.Config) == setup {
+			target = db.VariantName(c.name, n)
+			return target, target, nil
+		}
+	}
+	return "", newKey, nil
+}
+
+// newVariant hands out the first variant of the team named name that neither
+// exists nor was handed out earlier in the batch
+func (in *Ingester) newVariant(name string) string {
+	existing := in.index.Variants(name)
+	if in.reserved == nil {
+		in.reserved = make(map[string]bool)
+	}
+	for n := 1; ; n++ {
+		target := db.VariantName(name, n)
+		if _, ok := existing[n]; !ok && !in.reserved[target] {
+			in.reserved[target] = true
+			return target
+		}
+	}
+}
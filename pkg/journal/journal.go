@@ -0,0 +1,117 @@
+// Package journal keeps track of how far a run got so an interrupted run can
+// be resumed instead of started over.
+package journal
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Entry is the progress of a single pack, keyed by its path at the start of the run
+type Entry struct {
+	//sim output is in the tmp folder
+	Simulated bool `json:"simulated"`
+	//the yaml has been updated from the sim output
+	Written bool `json:"written"`
+	//the result is on the viewer under ViewerKey
+	Uploaded  bool   `json:"uploaded"`
+	ViewerKey string `json:"viewer_key,omitempty"`
+}
+
+// Journal is saved to disk after every change, so it is always as up to date
+// as the run it describes
+type Journal struct {
+	Started time.Time `json:"started"`
+	//gcsim version the run was started with
+	Version  string            `json:"version"`
+	Ingested bool              `json:"ingested"`
+	Indexed  bool              `json:"indexed"`
+	Packs    map[string]*Entry `json:"packs"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// New starts an empty journal that will be saved at path
+func New(path string) *Journal {
+	return &Journal{
+		Started: time.Now(),
+		Packs:   make(map[string]*Entry),
+		path:    path,
+	}
+}
+
+// Open loads the journal saved at path
+func Open(path string) (*Journal, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading journal")
+	}
+	j := New(path)
+	err = json.Unmarshal(b, j)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading journal")
+	}
+	if j.Packs == nil {
+		j.Packs = make(map[string]*Entry)
+	}
+	return j, nil
+}
+
+// Get returns the progress of the pack at path
+func (j *Journal) Get(path string) Entry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if e, ok := j.Packs[path]; ok {
+		return *e
+	}
+	return Entry{}
+}
+
+// Mark updates the entry of the pack at path and saves the journal
+func (j *Journal) Mark(path string, f func(e *Entry)) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e, ok := j.Packs[path]
+	if !ok {
+		e = &Entry{}
+		j.Packs[path] = e
+	}
+	f(e)
+	return j.save()
+}
+
+// Update changes run level fields and saves the journal
+func (j *Journal) Update(f func(j *Journal)) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f(j)
+	return j.save()
+}
+
+// Remove deletes the saved journal once the run it describes is complete
+func (j *Journal) Remove() error {
+	err := os.Remove(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return errors.Wrap(err, "")
+}
+
+// save writes the journal to a temp file first so a crash mid write can't
+// leave a half written journal behind
+func (j *Journal) save() error {
+	b, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	err = os.WriteFile(j.path+".tmp", b, 0755)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	return errors.Wrap(os.Rename(j.path+".tmp", j.path), "")
+}
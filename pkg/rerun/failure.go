@@ -0,0 +1,60 @@
+package rerun
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/genshinsim/gcsimdb/pkg/sim"
+	"github.com/pkg/errors"
+)
+
+// Failure records why a single pack could not be rerun
+type Failure struct {
+	File     string `json:"file"`
+	Stage    string `json:"stage"`
+	Error    string `json:"error"`
+	Stderr   string `json:"stderr,omitempty"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// stageError tags an error with the step of the rerun it came from
+type stageError struct {
+	stage string
+	err   error
+}
+
+func (e *stageError) Error() string { return e.stage + ": " + e.err.Error() }
+func (e *stageError) Cause() error  { return e.err }
+
+func newFailure(path string, err error) Failure {
+	f := Failure{
+		File:     path,
+		Stage:    "sim",
+		Error:    errors.Cause(err).Error(),
+		ExitCode: -1,
+	}
+	var se *stageError
+	if errors.As(err, &se) {
+		f.Stage = se.stage
+	}
+	var ge *sim.SimError
+	if errors.As(err, &ge) {
+		f.ExitCode = ge.ExitCode
+		f.Stderr = ge.Stderr
+	}
+	return f
+}
+
+// WriteFailureReport saves failed as json so it can be picked up by other tools
+func WriteFailureReport(path string, failed []Failure) error {
+	//an empty list rather than null
+	if failed == nil {
+		failed = []Failure{}
+	}
+	out, err := json.MarshalIndent(failed, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	err = os.WriteFile(path, out, 0755)
+	return errors.Wrap(err, "")
+}
@@ -0,0 +1,176 @@
+package rerun
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/genshinsim/gcsimdb/pkg/db"
+	"github.com/pkg/errors"
+)
+
+// Default thresholds for flagging a dps change
+const (
+	//standard errors the new mean has to be away from the old one
+	DefaultRegressionZ = 4.0
+	//and at least this much of a change, in percent
+	DefaultRegressionPct = 1.0
+)
+
+// Regression is a team whose dps changed by more than sim noise explains
+// between two gcsim versions, with the config left as it was
+type Regression struct {
+	File    string  `json:"file"`
+	From    string  `json:"from_version"`
+	To      string  `json:"to_version"`
+	OldMean float64 `json:"old_mean"`
+	NewMean float64 `json:"new_mean"`
+	//0 for teams rerun before dps stats were kept
+	OldSD float64 `json:"old_sd"`
+	NewSD float64 `json:"new_sd"`
+	//change of the mean, in percent of the old one
+	Change float64 `json:"change_pct"`
+	//change of the mean, in standard errors; 0 when NoNoise
+	Z float64 `json:"z"`
+	//neither run had any spread, so the change was flagged on percent alone
+	NoNoise bool     `json:"no_noise,omitempty"`
+	Chars   []string `json:"chars"`
+	Weapons []string `json:"weapons"`
+}
+
+// checkRegression compares the dps of p after a rerun with what prev had.
+// Teams without earlier results or whose config changed are never flagged.
+// Teams rerun before dps stats were kept are taken to have had the same
+// standard error as the new run.
+func checkRegression(prev, p db.Pack, opts Options) (Regression, bool) {
+	if prev.Hash == "" || prev.DPS <= 0 || prev.ConfigHash != "" && prev.ConfigHash != p.ConfigHash {
+		return Regression{}, false
+	}
+	se := p.DPSStats.SE
+	oldSE := se
+	if prev.DPSStats.Known() {
+		oldSE = prev.DPSStats.SE
+	}
+	diff := p.DPS - prev.DPS
+	//with no spread any change is beyond noise, so only the percent counts
+	var z float64
+	spread := math.Hypot(se, oldSE)
+	if spread > 0 {
+		z = diff / spread
+	}
+	change := diff / prev.DPS * 100
+
+	minZ, minPct := opts.RegressionZ, opts.RegressionPct
+	if minZ == 0 {
+		minZ = DefaultRegressionZ
+	}
+	if minPct == 0 {
+		minPct = DefaultRegressionPct
+	}
+	if spread > 0 && math.Abs(z) < minZ || math.Abs(change) < minPct {
+		return Regression{}, false
+	}
+
+	reg := Regression{
+		File:    p.Path,
+		From:    prev.Hash,
+		To:      p.Hash,
+		OldMean: prev.DPS,
+		NewMean: p.DPS,
+		OldSD:   prev.DPSStats.SD,
+		NewSD:   p.DPSStats.SD,
+		Change:  change,
+		Z:       z,
+		NoNoise: spread == 0,
+	}
+	for _, c := range p.Team {
+		reg.Chars = append(reg.Chars, c.Name)
+		reg.Weapons = append(reg.Weapons, c.Weapon)
+	}
+	return reg, true
+}
+
+// PrintRegressions lists the flagged teams grouped by character and by
+// weapon, so a change shared by every team with some character or weapon
+// stands out
+func PrintRegressions(regs []Regression) {
+	if len(regs) == 0 {
+		return
+	}
+	fmt.Printf("%v teams changed dps by more than sim noise explains:\n", len(regs))
+	for _, r := range regs {
+		if r.NoNoise {
+			fmt.Printf("\t%v: %.0f -> %.0f (%+.1f%%, no sim noise)\n", r.File, r.OldMean, r.NewMean, r.Change)
+			continue
+		}
+		fmt.Printf("\t%v: %.0f -> %.0f (%+.1f%%, z=%.1f)\n", r.File, r.OldMean, r.NewMean, r.Change, r.Z)
+	}
+	printGroups("character", regs, func(r Regression) []string { return r.Chars })
+	printGroups("weapon", regs, func(r Regression) []string { return r.Weapons })
+}
+
+// regressionGroup is the flagged teams sharing a character or weapon
+type regressionGroup struct {
+	key string
+	n   int
+	//sum of the changes, in percent
+	total float64
+}
+
+// groupRegressions groups regs by every key a team has, largest group first
+func groupRegressions(regs []Regression, keys func(r Regression) []string) []*regressionGroup {
+	groups := make(map[string]*regressionGroup)
+	for _, r := range regs {
+		seen := make(map[string]bool)
+		for _, k := range keys(r) {
+			if k == "" || seen[k] {
+				continue
+			}
+			seen[k] = true
+			g, ok := groups[k]
+			if !ok {
+				g = &regressionGroup{key: k}
+				groups[k] = g
+			}
+			g.n++
+			g.total += r.Change
+		}
+	}
+	var list []*regressionGroup
+	for _, g := range groups {
+		list = append(list, g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].n != list[j].n {
+			return list[i].n > list[j].n
+		}
+		return list[i].key < list[j].key
+	})
+	return list
+}
+
+func printGroups(kind string, regs []Regression, keys func(r Regression) []string) {
+	fmt.Printf("By %v:\n", kind)
+	var lines []string
+	for _, g := range groupRegressions(regs, keys) {
+		lines = append(lines, fmt.Sprintf("\t%-20v %3v teams, average %+.1f%%", g.key, g.n, g.total/float64(g.n)))
+	}
+	fmt.Println(strings.Join(lines, "\n"))
+}
+
+// WriteRegressionReport saves regs as json so it can be picked up by other tools
+func WriteRegressionReport(path string, regs []Regression) error {
+	//an empty list rather than null
+	if regs == nil {
+		regs = []Regression{}
+	}
+	out, err := json.MarshalIndent(regs, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	err = os.WriteFile(path, out, 0755)
+	return errors.Wrap(err, "")
+}
@@ -0,0 +1,91 @@
+package rerun
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/genshinsim/gcsimdb/pkg/db"
+	"github.com/genshinsim/gcsimdb/pkg/sim"
+)
+
+// ranPack is a team rerun on version with a mean dps of mean and an sd of sd
+// over 1000 iterations
+func ranPack(version string, mean, sd float64) db.Pack {
+	return db.Pack{
+		Path:       "team.yaml",
+		Hash:       version,
+		ConfigHash: "cfg",
+		DPS:        mean,
+		DPSStats:   db.NewStats(sim.FloatResult{Mean: mean, SD: sd}, 1000),
+		Team:       []db.Char{{Name: "fischl", Weapon: "thestringless"}, {Name: "bennett", Weapon: "aquilafavonia"}},
+	}
+}
+
+func TestCheckRegression(t *testing.T) {
+	noStats := ranPack("v1", 10000, 0)
+	noStats.DPSStats = db.Stats{}
+	edited := ranPack("v1", 10000, 1000)
+	edited.ConfigHash = "other"
+	cases := []struct {
+		name    string
+		prev, p db.Pack
+		flag    bool
+		noNoise bool
+	}{
+		{"noise", ranPack("v1", 10000, 1000), ranPack("v2", 10050, 1000), false, false},
+		{"real drop", ranPack("v1", 10000, 1000), ranPack("v2", 9000, 1000), true, false},
+		//many standard errors away but under the percent threshold
+		{"tiny change", ranPack("v1", 10000, 10), ranPack("v2", 10050, 10), false, false},
+		{"old run without stats", noStats, ranPack("v2", 9000, 1000), true, false},
+		{"never run", db.Pack{}, ranPack("v2", 9000, 1000), false, false},
+		{"config edited", edited, ranPack("v2", 9000, 1000), false, false},
+		//no spread at all: only the percent decides
+		{"no noise, change", ranPack("v1", 10000, 0), ranPack("v2", 9000, 0), true, true},
+		{"no noise, tiny change", ranPack("v1", 10000, 0), ranPack("v2", 10050, 0), false, false},
+	}
+	for _, c := range cases {
+		reg, ok := checkRegression(c.prev, c.p, Options{})
+		if ok != c.flag {
+			t.Errorf("%v: flagged = %v, want %v (%+v)", c.name, ok, c.flag, reg)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if reg.NoNoise != c.noNoise {
+			t.Errorf("%v: no noise = %v, want %v", c.name, reg.NoNoise, c.noNoise)
+		}
+		if !reflect.DeepEqual(reg.Chars, []string{"fischl", "bennett"}) || reg.From != c.prev.Hash || reg.To != "v2" {
+			t.Errorf("%v: got %+v", c.name, reg)
+		}
+		//the report has to be writable
+		if _, err := json.Marshal(reg); err != nil {
+			t.Errorf("%v: %v", c.name, err)
+		}
+	}
+}
+
+func TestGroupRegressions(t *testing.T) {
+	regs := []Regression{
+		{Chars: []string{"fischl", "bennett"}, Change: -10},
+		{Chars: []string{"fischl", "xingqiu"}, Change: -20},
+		//a character twice in a team counts once
+		{Chars: []string{"bennett", "bennett"}, Change: 6},
+		{Chars: []string{"", "xingqiu"}, Change: 4},
+	}
+	groups := groupRegressions(regs, func(r Regression) []string { return r.Chars })
+	type row struct {
+		key   string
+		n     int
+		total float64
+	}
+	var got []row
+	for _, g := range groups {
+		got = append(got, row{g.key, g.n, g.total})
+	}
+	want := []row{{"bennett", 2, -4}, {"fischl", 2, -30}, {"xingqiu", 2, -16}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groups = %+v, want %+v", got, want)
+	}
+}
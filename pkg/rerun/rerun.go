@@ -0,0 +1,365 @@
+// Package rerun resimulates db teams with the current gcsim build.
+package rerun
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/genshinsim/gcsimdb/pkg/db"
+	"github.com/genshinsim/gcsimdb/pkg/journal"
+	"github.com/genshinsim/gcsimdb/pkg/sim"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Stale modes decide which teams need a rerun
+const (
+	//ingest clears the hash of new and updated teams; rerun those and any
+	//team whose config was edited by hand
+	StaleEmpty = "empty"
+	//also rerun whenever the stored hash differs from the gcsim version; the
+	//default
+	StaleVersion = "version"
+)
+
+type Options struct {
+	Runner sim.Runner
+	//rerun every team regardless of StaleMode
+	Force bool
+	//number of sims to run at once
+	Workers int
+	//carry on with the rest of the run when some sims fail
+	KeepGoing bool
+	//where to write the failure report
+	ReportPath string
+	StaleMode  string
+	//where sim configs and results are kept; wiped at the start of each run
+	//unless resuming
+	TmpDir string
+	//progress is recorded here when set
+	Journal *journal.Journal
+	//pick up the output of packs the journal says were already rerun
+	Resume bool
+	//only report what would be rerun; nothing is simmed or written
+	DryRun bool
+	//where to write the dps regression report
+	RegressionPath string
+	//a dps change is flagged when it is at least RegressionZ standard errors
+	//and RegressionPct percent; 0 uses the defaults
+	RegressionZ   float64
+	RegressionPct float64
+}
+
+// Process reruns every stale pack in data and writes back its yaml. Sim
+// output is left in opts.TmpDir for a later upload.
+func Process(data []db.Pack, latest string, opts Options) error {
+	if opts.DryRun {
+		PrintPlan(Plan(data, latest, opts), len(data), latest)
+		return nil
+	}
+
+	//make a tmp folder if it doesn't exist
+	if _, err := os.Stat(opts.TmpDir); !opts.Resume && !os.IsNotExist(err) {
+		fmt.Println("tmp folder already exists, deleting...")
+		// path/to/whatever exists
+		os.RemoveAll(opts.TmpDir)
+	}
+	os.MkdirAll(opts.TmpDir, 0755)
+
+	fmt.Println("Rerunning configs...")
+
+	var queue []int
+	var pending []int
+	resumed := 0
+	for i := range data {
+		if opts.Resume && opts.Journal != nil {
+			e := opts.Journal.Get(data[i].Path)
+			//the yaml is already up to date, only the upload needs the output again
+			if e.Written && LoadTmpResult(&data[i], opts.TmpDir) {
+				fmt.Printf("\tAlready rerun %v\n", data[i].Path)
+				resumed++
+				continue
+			}
+			//the tmp folder was cleared since; the yaml needs nothing, only an upload is lost
+			if e.Written {
+				if e.Uploaded {
+					fmt.Printf("\tAlready rerun and uploaded %v\n", data[i].Path)
+				} else {
+					fmt.Printf("\tAlready rerun %v, but its sim output is gone so it won't be uploaded\n", data[i].Path)
+				}
+				resumed++
+				continue
+			}
+			//sim finished but the yaml was never written
+			if e.Simulated {
+				data[i].Config = sim.FixConfig(data[i].Config)
+				pending = append(pending, i)
+				continue
+			}
+		}
+
+		//only rerun if changed or forced
+		reason := StaleReason(data[i], latest, opts.StaleMode)
+		if !opts.Force && reason == "" {
+			fmt.Printf("\tSkipping %v\n", data[i].Path)
+			//rerun on latest before config hashes were kept; record it so later edits show up
+			if data[i].Hash == latest && data[i].ConfigHash == "" {
+				data[i].ConfigHash = sim.ConfigHash(data[i].Config)
+			}
+			continue
+		}
+		if reason != "" {
+			fmt.Printf("\tQueued %v: %v\n", data[i].Path, reason)
+		}
+
+		//fix the iterations
+		data[i].Config = sim.FixConfig(data[i].Config)
+		queue = append(queue, i)
+	}
+
+	//re run sims in parallel; each worker only touches its own slot in results
+	results := runPool(data, queue, opts)
+	for _, i := range pending {
+		outPath := TmpPath(opts.TmpDir, data[i])
+		jsonData, err := os.ReadFile(outPath + ".json")
+		results[i] = simResult{outPath: outPath, jsonData: jsonData, err: err}
+	}
+	queue = append(queue, pending...)
+	sort.Ints(queue)
+
+	//write everything out in db order so the output doesn't depend on which sim finished first
+	var failed []Failure
+	var regs []Regression
+	for _, i := range queue {
+		prev := data[i]
+		err := writeResult(&data[i], latest, results[i])
+		if err == nil {
+			if reg, ok := checkRegression(prev, data[i], opts); ok {
+				regs = append(regs, reg)
+			}
+		}
+		if err == nil && opts.Journal != nil {
+			err = opts.Journal.Mark(data[i].Path, func(e *journal.Entry) { e.Written = true })
+		}
+		if err != nil {
+			fmt.Printf("\tFailed %v: %v\n", data[i].Path, err)
+			failed = append(failed, newFailure(data[i].Path, err))
+		}
+	}
+
+	fmt.Printf("Rerun finished: %v succeeded, %v failed, %v skipped, %v already done\n", len(queue)-len(failed), len(failed), len(data)-len(queue)-resumed, resumed)
+	for _, f := range failed {
+		fmt.Printf("\tFAILED: %v (%v, exit code %v): %v\n", f.File, f.Stage, f.ExitCode, f.Error)
+	}
+	//written on every run so reports left by an earlier run don't linger
+	PrintRegressions(regs)
+	err := WriteRegressionReport(opts.RegressionPath, regs)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	err = WriteFailureReport(opts.ReportPath, failed)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	if len(regs) > 0 {
+		fmt.Printf("Regression report written to %v\n", opts.RegressionPath)
+	}
+	if len(failed) > 0 {
+		fmt.Printf("Failure report written to %v\n", opts.ReportPath)
+		//failed teams keep their old results; carry on with the rest if asked to
+		if !opts.KeepGoing {
+			return errors.Errorf("%v of %v sims failed", len(failed), len(queue))
+		}
+	}
+
+	return nil
+}
+
+// IsStale reports whether p needs a rerun against gcsim version latest
+func IsStale(p db.Pack, latest string, mode string) bool {
+	return StaleReason(p, latest, mode) != ""
+}
+
+// StaleReason says why p needs a rerun against gcsim version latest, or
+// returns "" if it doesn't. An empty mode is StaleVersion. A pack rerun before
+// config hashes were recorded has nothing to compare its config to, so only a
+// version change makes it stale.
+func StaleReason(p db.Pack, latest string, mode string) string {
+	switch {
+	case p.Hash == "":
+		return "new or updated submission"
+	case p.ConfigHash != "" && p.ConfigHash != sim.ConfigHash(p.Config):
+		return "config edited since last rerun"
+	case mode == StaleEmpty:
+		return ""
+	case p.Hash != latest:
+		//compare hash vs current hash; if not the same rerun
+		return "gcsim version changed"
+	}
+	return ""
+}
+
+// PrintPlan lists plan and sums it up by reason
+func PrintPlan(plan []PlanItem, total int, latest string) {
+	counts := make(map[string]int)
+	var reasons []string
+	for _, p := range plan {
+		fmt.Printf("\t[dry-run] would rerun %v: %v\n", p.Path, p.Reason)
+		if counts[p.Reason] == 0 {
+			reasons = append(reasons, p.Reason)
+		}
+		counts[p.Reason]++
+	}
+	fmt.Printf("%v of %v teams would be rerun against %v\n", len(plan), total, latest)
+	for _, reason := range reasons {
+		fmt.Printf("\t%v: %v\n", reason, counts[reason])
+	}
+}
+
+// PlanItem is a pack Process would rerun
+type PlanItem struct {
+	Path   string
+	Reason string
+}
+
+// Plan lists the packs Process would rerun with opts, without running anything
+func Plan(data []db.Pack, latest string, opts Options) []PlanItem {
+	var plan []PlanItem
+	for _, p := range data {
+		if opts.Resume && opts.Journal != nil && opts.Journal.Get(p.Path).Written {
+			continue
+		}
+		reason := StaleReason(p, latest, opts.StaleMode)
+		if reason == "" && opts.Force {
+			reason = "forced"
+		}
+		if reason != "" {
+			plan = append(plan, PlanItem{Path: p.Path, Reason: reason})
+		}
+	}
+	return plan
+}
+
+// TmpPath is where the sim output for p is kept in tmpDir. It's derived from the
+// yaml path so parallel sims never share a file and a later upload can find it again
+func TmpPath(tmpDir string, p db.Pack) string {
+	rel := filepath.Base(filepath.Dir(p.Path)) + "_" + filepath.Base(p.Path)
+	rel = strings.TrimSuffix(rel, filepath.Ext(rel))
+	return filepath.Join(tmpDir, strings.ReplaceAll(rel, " ", "_"))
+}
+
+// LoadTmpResults picks up the sim output a previous rerun left in tmpDir
+func LoadTmpResults(data []db.Pack, tmpDir string) {
+	for i := range data {
+		LoadTmpResult(&data[i], tmpDir)
+	}
+}
+
+// LoadTmpResult picks up the sim output for p, reporting whether there was any
+func LoadTmpResult(p *db.Pack, tmpDir string) bool {
+	outPath := TmpPath(tmpDir, *p)
+	if _, err := os.Stat(outPath + ".gz"); err != nil {
+		return false
+	}
+	jsonData, err := os.ReadFile(outPath + ".json")
+	if err != nil {
+		return false
+	}
+	p.Changed = true
+	p.GzPath = outPath + ".gz"
+	json.Unmarshal(jsonData, &p.Result)
+	return true
+}
+
+type simResult struct {
+	outPath  string
+	jsonData []byte
+	err      error
+}
+
+// runPool runs the sims for the given indices using at most opts.Workers sims at a time
+func runPool(data []db.Pack, queue []int, opts Options) []simResult {
+	results := make([]simResult, len(data))
+	jobs := make(chan int)
+
+	n := opts.Workers
+	if n < 1 {
+		n = 1
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fmt.Printf("\tRerunning %v\n", data[i].Path)
+				outPath := TmpPath(opts.TmpDir, data[i])
+				res := simResult{outPath: outPath}
+				res.jsonData, res.err = opts.Runner.Run(data[i].Config, outPath)
+				if res.err == nil && opts.Journal != nil {
+					res.err = opts.Journal.Mark(data[i].Path, func(e *journal.Entry) { e.Simulated = true })
+				}
+				results[i] = res
+			}
+		}()
+	}
+
+	for _, i := range queue {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// writeResult populates p from a finished sim and writes its yaml and gz.
+// p is left untouched if anything fails so the team keeps its previous results
+func writeResult(p *db.Pack, latest string, res simResult) error {
+	if res.err != nil {
+		return errors.Wrap(res.err, "")
+	}
+	np := *p
+	np.Changed = true
+
+	//read the json and populate
+	np.Hash = latest
+	np.ConfigHash = sim.ConfigHash(np.Config)
+	r, err := sim.ParseResult(res.jsonData)
+	if err == nil {
+		err = np.ApplyResult(r)
+	}
+	if err != nil {
+		return &stageError{stage: "parse", err: err}
+	}
+
+	//find the mode
+	if mode := sim.Mode(np.Config); mode != "" {
+		np.Mode = mode
+	}
+	np.RecordResult(*p)
+
+	//overwrite yaml
+	out, err := yaml.Marshal(np)
+	if err != nil {
+		return &stageError{stage: "write", err: err}
+	}
+	err = os.WriteFile(np.Path, out, 0755)
+	if err != nil {
+		return &stageError{stage: "write", err: err}
+	}
+
+	//write gz
+	sim.WriteGZ(res.jsonData, res.outPath)
+	np.GzPath = res.outPath + ".gz"
+
+	*p = np
+
+	return nil
+}
@@ -0,0 +1,303 @@
+package rerun
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/genshinsim/gcsimdb/pkg/db"
+	"github.com/genshinsim/gcsimdb/pkg/journal"
+	"github.com/genshinsim/gcsimdb/pkg/sim"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const latest = "v2"
+
+const resultJSON = `{
+	"config_file": "bennett char lvl=90/90;",
+	"sim_duration": {"mean": 90},
+	"dps": {"min": 20000, "max": 40000, "mean": 30000, "sd": 3000},
+	"iter": 1000,
+	"target_details": [{"level": 100}],
+	"char_details": [
+		{"name": "fischl", "cons": 6, "weapon": {"name": "thestringless", "refine": 3}, "stats": [0, 0, 0, 0, 0, 0, 0, 0.2]},
+		{"name": "bennett", "cons": 6, "weapon": {"name": "aquilafavonia", "refine": 1}, "stats": [0, 0, 0, 0, 0, 0, 0, 0.5]}
+	],
+	"damage_by_char_by_targets": [{"1": {"mean": 24000}}, {"1": {"mean": 6000}}]
+}`
+
+// writePacks saves packs into a temp db and returns them as Load would
+func writePacks(t *testing.T, packs ...db.Pack) []db.Pack {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "Fischl")
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range packs {
+		packs[i].Path = filepath.Join(dir, packs[i].Path)
+	}
+	err = db.Save(packs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := db.Load(filepath.Dir(dir), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].Path < data[j].Path })
+	return data
+}
+
+func testOptions(t *testing.T, runner sim.Runner) Options {
+	dir := t.TempDir()
+	return Options{
+		Runner:         runner,
+		Workers:        2,
+		StaleMode:      StaleVersion,
+		TmpDir:         filepath.Join(dir, "tmp"),
+		ReportPath:     filepath.Join(dir, "failures.json"),
+		RegressionPath: filepath.Join(dir, "regressions.json"),
+	}
+}
+
+func TestProcessWritesResults(t *testing.T) {
+	data := writePacks(t, db.Pack{Path: "bnfs.yaml", Config: "bennett char lvl=90/90;\noptions iteration=50 workers=4;"})
+	fake := &sim.Fake{Hash: latest, Default: []byte(resultJSON)}
+
+	err := Process(data, latest, testOptions(t, fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := db.ReadFile(data[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Hash != latest {
+		t.Errorf("hash = %q, want %q", p.Hash, latest)
+	}
+	if p.ConfigHash != sim.ConfigHash(p.Config) {
+		t.Errorf("config hash %q doesn't match config", p.ConfigHash)
+	}
+	if !strings.Contains(p.Config, "iteration=1000") {
+		t.Errorf("config wasn't fixed: %q", p.Config)
+	}
+	if p.DPS != 30000 || p.DPSStats.SD != 3000 || p.DPSStats.Iterations != 1000 {
+		t.Errorf("dps = %v, stats = %+v", p.DPS, p.DPSStats)
+	}
+	if len(p.Team) != 2 || p.Team[0].Name != "bennett" || p.Team[1].Name != "fischl" {
+		t.Fatalf("team = %+v, want bennett and fischl", p.Team)
+	}
+	if got := p.Team[1].DPSPct; len(got) != 1 || got[0] != 80 {
+		t.Errorf("fischl dps_pct = %v, want [80]", got)
+	}
+	if len(p.History) != 1 || p.History[0].Version != latest {
+		t.Errorf("history = %+v, want one revision on %v", p.History, latest)
+	}
+	if _, err := os.Stat(data[0].GzPath); err != nil {
+		t.Errorf("no gz written: %v", err)
+	}
+}
+
+func TestProcessReportsFailures(t *testing.T) {
+	data := writePacks(t,
+		db.Pack{Path: "bad.yaml", Config: "bad;"},
+		db.Pack{Path: "good.yaml", Config: "good;"},
+	)
+	fake := &sim.Fake{
+		Hash: latest,
+		Respond: func(cfg string) ([]byte, error) {
+			if cfg == "bad;" {
+				return nil, &sim.SimError{ExitCode: 2, Stderr: "boom", Err: errors.New("exit status 2")}
+			}
+			return []byte(resultJSON), nil
+		},
+	}
+	opts := testOptions(t, fake)
+
+	err := Process(data, latest, opts)
+	if err == nil {
+		t.Fatal("want an error for the failed sim")
+	}
+
+	b, err := os.ReadFile(opts.ReportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failed []Failure
+	err = json.Unmarshal(b, &failed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Failure{File: data[0].Path, Stage: "sim", Error: "exit status 2", Stderr: "boom", ExitCode: 2}
+	if len(failed) != 1 || failed[0] != want {
+		t.Errorf("report = %+v, want %+v", failed, want)
+	}
+
+	//the failed team keeps its old yaml, the other one is still written
+	b, err = os.ReadFile(data[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bad db.Pack
+	err = yaml.Unmarshal(b, &bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bad.Hash != "" {
+		t.Errorf("failed team got hash %q", bad.Hash)
+	}
+	good, err := db.ReadFile(data[1].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if good.Hash != latest {
+		t.Errorf("good team hash = %q, want %q", good.Hash, latest)
+	}
+}
+
+func TestProcessKeepGoing(t *testing.T) {
+	data := writePacks(t, db.Pack{Path: "bad.yaml", Config: "bad;"})
+	fake := &sim.Fake{Hash: latest}
+	opts := testOptions(t, fake)
+	opts.KeepGoing = true
+
+	err := Process(data, latest, opts)
+	if err != nil {
+		t.Fatalf("want no error with KeepGoing, got %v", err)
+	}
+	if _, err := os.Stat(opts.ReportPath); err != nil {
+		t.Errorf("no failure report: %v", err)
+	}
+}
+
+func TestProcessClearsOldReports(t *testing.T) {
+	data := writePacks(t, db.Pack{Path: "bnfs.yaml", Config: "bennett char lvl=90/90;"})
+	fake := &sim.Fake{Hash: latest, Default: []byte(resultJSON)}
+	opts := testOptions(t, fake)
+	for _, path := range []string{opts.ReportPath, opts.RegressionPath} {
+		err := os.WriteFile(path, []byte(`[{"file": "old.yaml"}]`), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := Process(data, latest, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{opts.ReportPath, opts.RegressionPath} {
+		b, err := os.ReadFile(path)
+		if err != nil || string(b) != "[]" {
+			t.Errorf("%v = %q, %v; want an empty list after a clean run", filepath.Base(path), b, err)
+		}
+	}
+}
+
+func TestProcessQueuesStale(t *testing.T) {
+	fresh := "fresh;"
+	edited := "edited;"
+	old := "old;"
+	data := writePacks(t,
+		db.Pack{Path: "a.yaml", Config: fresh, Hash: latest, ConfigHash: sim.ConfigHash(fresh)},
+		db.Pack{Path: "b.yaml", Config: edited, Hash: latest, ConfigHash: sim.ConfigHash("before;")},
+		db.Pack{Path: "c.yaml", Config: old, Hash: "v1", ConfigHash: sim.ConfigHash(old)},
+		db.Pack{Path: "d.yaml", Config: "new;"},
+	)
+
+	cases := []struct {
+		mode  string
+		force bool
+		want  []string
+	}{
+		{StaleVersion, false, []string{edited, "new;", old}},
+		{"", false, []string{edited, "new;", old}},
+		{StaleEmpty, false, []string{edited, "new;"}},
+		{StaleEmpty, true, []string{edited, fresh, "new;", old}},
+	}
+	for _, c := range cases {
+		fake := &sim.Fake{Hash: latest, Default: []byte(resultJSON)}
+		opts := testOptions(t, fake)
+		opts.StaleMode = c.mode
+		opts.Force = c.force
+		//each case starts from the packs as written, not the last case's results
+		run := append([]db.Pack(nil), data...)
+
+		err := Process(run, latest, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := fake.Calls()
+		sort.Strings(got)
+		if strings.Join(got, " ") != strings.Join(c.want, " ") {
+			t.Errorf("mode %q force %v: ran %v, want %v", c.mode, c.force, got, c.want)
+		}
+	}
+}
+
+func TestProcessBackfillsConfigHash(t *testing.T) {
+	data := writePacks(t, db.Pack{Path: "a.yaml", Config: "cfg;", Hash: latest})
+	fake := &sim.Fake{Hash: latest}
+	err := Process(data, latest, testOptions(t, fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.Calls()) > 0 {
+		t.Errorf("ran %v, want nothing rerun for a missing config hash", fake.Calls())
+	}
+	if data[0].ConfigHash != sim.ConfigHash("cfg;") {
+		t.Errorf("config hash = %q, want it backfilled", data[0].ConfigHash)
+	}
+}
+
+func TestProcessResumeWrittenWithoutOutput(t *testing.T) {
+	data := writePacks(t, db.Pack{Path: "a.yaml", Config: "cfg;"})
+	fake := &sim.Fake{Hash: latest}
+	opts := testOptions(t, fake)
+	opts.Resume = true
+	opts.Journal = journal.New(filepath.Join(t.TempDir(), "journal.json"))
+	err := opts.Journal.Mark(data[0].Path, func(e *journal.Entry) { e.Simulated, e.Written = true, true })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Process(data, latest, opts)
+	if err != nil {
+		t.Fatalf("want the written pack skipped, got %v", err)
+	}
+	if len(fake.Calls()) > 0 {
+		t.Errorf("ran %v, want nothing rerun", fake.Calls())
+	}
+}
+
+func TestStaleReason(t *testing.T) {
+	cfg := "cfg;"
+	hash := sim.ConfigHash(cfg)
+	cases := []struct {
+		name string
+		p    db.Pack
+		mode string
+		want string
+	}{
+		{"new", db.Pack{Config: cfg}, StaleEmpty, "new or updated submission"},
+		{"edited", db.Pack{Config: cfg, Hash: latest, ConfigHash: "x"}, StaleEmpty, "config edited since last rerun"},
+		{"fresh", db.Pack{Config: cfg, Hash: latest, ConfigHash: hash}, StaleVersion, ""},
+		{"old version", db.Pack{Config: cfg, Hash: "v1", ConfigHash: hash}, StaleVersion, "gcsim version changed"},
+		{"old version, empty mode", db.Pack{Config: cfg, Hash: "v1", ConfigHash: hash}, StaleEmpty, ""},
+		{"old version, default mode", db.Pack{Config: cfg, Hash: "v1", ConfigHash: hash}, "", "gcsim version changed"},
+		//a missing hash is not a change
+		{"no config hash", db.Pack{Config: cfg, Hash: latest}, StaleVersion, ""},
+		{"no config hash, old version", db.Pack{Config: cfg, Hash: "v1"}, StaleVersion, "gcsim version changed"},
+		{"no config hash, empty mode", db.Pack{Config: cfg, Hash: latest}, StaleEmpty, ""},
+	}
+	for _, c := range cases {
+		if got := StaleReason(c.p, latest, c.mode); got != c.want {
+			t.Errorf("%v: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
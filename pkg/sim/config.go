@@ -0,0 +1,57 @@
+package sim
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var reIter = regexp.MustCompile(`iteration=(\d+)`)
+var reWorkers = regexp.MustCompile(`workers=(\d+)`)
+var reMode = regexp.MustCompile(`mode=(\w+)`)
+
+// FixConfig sets the iterations and workers every db config is run with
+func FixConfig(cfg string) string {
+	cfg = reIter.ReplaceAllString(cfg, "iteration=1000")
+	cfg = reWorkers.ReplaceAllString(cfg, "workers=30")
+	return cfg
+}
+
+// Mode returns the sim mode set in cfg, or "" if there is none
+func Mode(cfg string) string {
+	match := reMode.FindStringSubmatch(cfg)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// ConfigHash fingerprints cfg so edits can be told apart from reformatting.
+// Comments, blank lines, indentation and the iterations/workers FixConfig
+// overrides don't change the hash.
+func ConfigHash(cfg string) string {
+	var lines []string
+	for _, line := range strings.Split(FixConfig(cfg), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Iterations returns the number of iterations cfg runs, or 0 if it doesn't say
+func Iterations(cfg string) int {
+	match := reIter.FindStringSubmatch(cfg)
+	if match == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(match[1])
+	return n
+}
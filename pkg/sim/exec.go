@@ -0,0 +1,117 @@
+package sim
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SimError is returned by Run when gcsim itself exits with an error
+type SimError struct {
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+func (e *SimError) Error() string { return e.Err.Error() }
+func (e *SimError) Unwrap() error { return e.Err }
+
+// Download fetches url into path, replacing whatever is there
+func Download(path string, url string) error {
+	//remove if exists
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		fmt.Printf("%v already exists, deleting...\n", path)
+		// path/to/whatever exists
+		os.RemoveAll(path)
+	}
+
+	fmt.Printf("Downloading: %v\n", url)
+	// Get the data
+	resp, err := http.Get(url)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	defer resp.Body.Close()
+
+	// Create the file, runnable as it is the sim
+	out, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	defer out.Close()
+
+	// Write the body to file
+	_, err = io.Copy(out, resp.Body)
+	return errors.Wrap(err, "")
+}
+
+// ExecRunner runs a gcsim executable
+type ExecRunner struct {
+	Path string
+	//extra arguments passed on every run
+	Args []string
+}
+
+// NewExecRunner returns a runner for the gcsim binary at path
+func NewExecRunner(path string, args ...string) *ExecRunner {
+	return &ExecRunner{Path: path, Args: args}
+}
+
+// Version returns the commit hash of the gcsim build
+func (e *ExecRunner) Version() (string, error) {
+	fmt.Println("Getting last hash...")
+	out, err := exec.Command(e.Path, "-version").Output()
+	hash := strings.Trim(string(out), "\n")
+	fmt.Printf("Latest hash: %v\n", hash)
+	if err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// Run writes cfg to path.txt and sims it, leaving the result in path.json
+func (e *ExecRunner) Run(cfg, path string) ([]byte, error) {
+	//write config to file
+	err := os.WriteFile(path+".txt", []byte(cfg), 0755)
+	if err != nil {
+		// fmt.Printf("error saving config file: %v\n", err)
+		return nil, errors.Wrap(err, "")
+	}
+	args := append([]string{"-c", path + ".txt", "-out", path + ".json"}, e.Args...)
+	out, err := exec.Command(e.Path, args...).Output()
+
+	if err != nil {
+		fmt.Printf("%v\n", string(out))
+		se := &SimError{ExitCode: -1, Stderr: string(out), Err: err}
+		if ee, ok := err.(*exec.ExitError); ok {
+			se.ExitCode = ee.ExitCode()
+			se.Stderr = string(ee.Stderr)
+		}
+		return nil, errors.Wrap(se, "")
+	}
+
+	jsonData, err := os.ReadFile(path + ".json")
+	if err != nil {
+		return nil, errors.Wrap(err, "reading result")
+	}
+	return jsonData, nil
+}
+
+// WriteGZ gzips jsonData into fpath.gz
+func WriteGZ(jsonData []byte, fpath string) error {
+	f, err := os.OpenFile(fpath+".gz", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	zw.Write(jsonData)
+	err = zw.Close()
+	return errors.Wrap(err, "")
+}
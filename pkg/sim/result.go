@@ -0,0 +1,101 @@
+// Package sim wraps the gcsim command line tool and the result json it produces.
+package sim
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// ERIndex is the position of energy recharge in a character's stats
+const ERIndex = 7
+
+// Result is the part of a gcsim result json the db cares about. Share links
+// on the viewer hold the same json, so it's used for both.
+type Result struct {
+	Config     string          `json:"config_file"`
+	Duration   FloatResult     `json:"sim_duration"`
+	DPS        FloatResult     `json:"dps"`
+	NumTarget  int             `json:"target_count"`
+	Targets    []TargetDetail  `json:"target_details"`
+	Characters []CharDetail    `json:"char_details"`
+	CharDPS    []CharTargetDPS `json:"damage_by_char_by_targets"`
+	Iterations int             `json:"iter"`
+}
+
+type TargetDetail struct {
+	Level int `json:"level"`
+}
+
+type CharDetail struct {
+	Name   string `json:"name"`
+	Level  int    `json:"level"`
+	MaxLvl int    `json:"max_level"`
+	Cons   int    `json:"cons"`
+	Weapon struct {
+		Name   string `json:"name"`
+		Refine int    `json:"refine"`
+		Level  int    `json:"level"`
+		MaxLvl int    `json:"max_level"`
+	} `json:"weapon"`
+	Stats   []float64    `json:"stats"`
+	Talents TalentDetail `json:"talents"`
+}
+
+// CharTargetDPS is a character's dps against each target, keyed by target
+// number counting from 1
+type CharTargetDPS map[string]FloatResult
+
+// Target returns the dps against target i, counting from 0
+func (c CharTargetDPS) Target(i int) FloatResult {
+	return c[strconv.Itoa(i+1)]
+}
+
+// Targets returns how many targets c has dps for, up to the highest numbered one
+func (c CharTargetDPS) Targets() int {
+	n := 0
+	for k := range c {
+		if i, err := strconv.Atoi(k); err == nil && i > n {
+			n = i
+		}
+	}
+	return n
+}
+
+type TalentDetail struct {
+	Attack int `json:"attack"`
+	Skill  int `json:"skill"`
+	Burst  int `json:"burst"`
+}
+
+type FloatResult struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Mean float64 `json:"mean"`
+	SD   float64 `json:"sd"`
+}
+
+// ParseResult decodes a gcsim result json
+func ParseResult(jsonData []byte) (Result, error) {
+	var r Result
+	err := json.Unmarshal(jsonData, &r)
+	if err != nil {
+		return r, errors.Wrap(err, "")
+	}
+	return r, nil
+}
+
+// MainDPS returns the index of the character doing the most damage to the
+// first target, or -1 if the result has no damage breakdown
+func (r Result) MainDPS() int {
+	maxdps := 0.0
+	maxdpschar := -1
+	for i := range r.CharDPS {
+		if r.CharDPS[i].Target(0).Mean > maxdps {
+			maxdps = r.CharDPS[i].Target(0).Mean
+			maxdpschar = i
+		}
+	}
+	return maxdpschar
+}
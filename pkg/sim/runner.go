@@ -0,0 +1,65 @@
+package sim
+
+import (
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Runner sims configs. ExecRunner is the real thing; Fake stands in for it
+// where gcsim isn't available.
+type Runner interface {
+	//Version identifies the build; packs store it to know when they're stale
+	Version() (string, error)
+	//Run sims cfg and returns the result json. path is the prefix for any
+	//files the run leaves behind; the result is always written to path.json
+	Run(cfg, path string) ([]byte, error)
+}
+
+// Fake is a Runner that never starts gcsim and hands back canned result json
+type Fake struct {
+	Hash string
+	//Respond decides the result of each run; Default is returned when it's nil
+	Respond func(cfg string) ([]byte, error)
+	Default []byte
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (f *Fake) Version() (string, error) {
+	return f.Hash, nil
+}
+
+func (f *Fake) Run(cfg, path string) ([]byte, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, cfg)
+	f.mu.Unlock()
+
+	jsonData := f.Default
+	if f.Respond != nil {
+		var err error
+		jsonData, err = f.Respond(cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if jsonData == nil {
+		return nil, &SimError{ExitCode: 1, Stderr: "no canned result", Err: errors.New("fake: no result for config")}
+	}
+	if path != "" {
+		err := os.WriteFile(path+".json", jsonData, 0755)
+		if err != nil {
+			return nil, errors.Wrap(err, "")
+		}
+	}
+	return jsonData, nil
+}
+
+// Calls returns every config run so far, in the order the runs started
+func (f *Fake) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
@@ -0,0 +1,147 @@
+package viewer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/genshinsim/gcsimdb/pkg/sim"
+	"github.com/pkg/errors"
+)
+
+// Fetcher reads results from viewer share links, retrying flaky requests and
+// keeping what it read in a cache so the same link is only fetched once
+type Fetcher struct {
+	BaseURL string
+	//per request, including reading the body
+	Timeout time.Duration
+	//how many times a failed request is tried again
+	Retries int
+	//wait before the first retry; doubled for each one after
+	Backoff time.Duration
+	//decoded results are kept here as <id>.json; caching is off when empty
+	CacheDir string
+}
+
+// NewFetcher returns a fetcher for the viewer at baseURL with no cache
+func NewFetcher(baseURL string) *Fetcher {
+	return &Fetcher{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Timeout: 10 * time.Second,
+		Retries: 3,
+		Backoff: time.Second,
+	}
+}
+
+// retryable marks errors worth trying the request again for
+type retryable struct {
+	err error
+}
+
+func (e *retryable) Error() string { return e.err.Error() }
+func (e *retryable) Cause() error  { return e.err }
+
+// Fetch reads the result input points at (see Resolve), from the cache if
+// it's there. Local files are never cached.
+func (f *Fetcher) Fetch(input string) (sim.Result, error) {
+	src, err := Resolve(input)
+	if err != nil {
+		return sim.Result{}, err
+	}
+	if src.File != "" {
+		raw, err := readFile(src.File)
+		if err != nil {
+			return sim.Result{}, err
+		}
+		return parseResult(raw)
+	}
+	id := src.ID
+
+	if raw, ok := f.cached(id); ok {
+		return parseResult(raw)
+	}
+
+	var body []byte
+	wait := f.Backoff
+	for try := 0; ; try++ {
+		body, err = f.get(id)
+		var r *retryable
+		if err == nil || try >= f.Retries || !errors.As(err, &r) {
+			break
+		}
+		fmt.Printf("\t%v, retrying in %v\n", err, wait)
+		time.Sleep(wait)
+		wait *= 2
+	}
+	if err != nil {
+		return sim.Result{}, errors.Wrap(errors.Cause(err), "fetching result")
+	}
+
+	raw, err := decodeShare(body)
+	if err != nil {
+		return sim.Result{}, err
+	}
+	data, err := parseResult(raw)
+	if err != nil {
+		return sim.Result{}, err
+	}
+	//only cache results that parsed, so a bad one is fetched again next time
+	err = f.store(id, raw)
+	if err != nil {
+		return sim.Result{}, err
+	}
+	return data, nil
+}
+
+// get makes a single request for the share with id
+func (f *Fetcher) get(id string) ([]byte, error) {
+	client := http.Client{Timeout: f.Timeout}
+	res, err := client.Get(f.BaseURL + "/" + id)
+	if err != nil {
+		return nil, &retryable{err}
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, &retryable{err}
+	}
+	switch {
+	case res.StatusCode == http.StatusOK:
+		return body, nil
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return nil, &retryable{errors.Errorf("viewer returned %v", res.Status)}
+	}
+	return nil, errors.Errorf("viewer returned %v", res.Status)
+}
+
+func (f *Fetcher) cachePath(id string) string {
+	return filepath.Join(f.CacheDir, id+".json")
+}
+
+func (f *Fetcher) cached(id string) ([]byte, bool) {
+	if f.CacheDir == "" {
+		return nil, false
+	}
+	raw, err := os.ReadFile(f.cachePath(id))
+	if err != nil || !json.Valid(raw) {
+		return nil, false
+	}
+	return raw, true
+}
+
+func (f *Fetcher) store(id string, raw []byte) error {
+	if f.CacheDir == "" {
+		return nil
+	}
+	err := os.MkdirAll(f.CacheDir, 0755)
+	if err != nil {
+		return errors.Wrap(err, "caching result")
+	}
+	return errors.Wrap(os.WriteFile(f.cachePath(id), raw, 0755), "caching result")
+}
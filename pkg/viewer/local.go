@@ -0,0 +1,110 @@
+package viewer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+// indexFile is the name the db index is stored under
+const indexFile = "db.json"
+
+var reKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// LocalServer stands in for the viewer so full runs can be done offline. It
+// serves the same routes and keeps every blob as a file in Dir:
+//
+//	POST /key  stores a result under the VIEWER_KEY header (or a new key)
+//	POST /db   replaces the db index
+//	GET  /db   returns the db index
+//	GET  /{id} returns a stored result
+//
+// The API key is not checked.
+type LocalServer struct {
+	Dir string
+}
+
+// NewLocalServer returns a server keeping its blobs in dir, creating it if needed
+func NewLocalServer(dir string) (*LocalServer, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalServer{Dir: dir}, nil
+}
+
+func (s *LocalServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(r.URL.Path, "/")
+
+	switch {
+	case r.Method == http.MethodPost && id == "key":
+		s.postKey(w, r)
+	case r.Method == http.MethodPost && id == "db":
+		s.store(w, r, indexFile)
+	case r.Method == http.MethodGet && id == "db":
+		s.serve(w, indexFile)
+	case r.Method == http.MethodGet && reKey.MatchString(id):
+		s.serve(w, id+".json")
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func (s *LocalServer) postKey(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("VIEWER_KEY")
+	if key == "" {
+		var err error
+		key, err = gonanoid.New()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if !reKey.MatchString(key) || key == "key" || key == "db" {
+		http.Error(w, "invalid key", http.StatusBadRequest)
+		return
+	}
+	if !s.store(w, r, key+".json") {
+		return
+	}
+	json.NewEncoder(w).Encode(viewerRes{ID: key})
+}
+
+// store writes the request body to name, reporting any error to the client
+func (s *LocalServer) store(w http.ResponseWriter, r *http.Request, name string) bool {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if !json.Valid(body) {
+		http.Error(w, "body is not json", http.StatusBadRequest)
+		return false
+	}
+	err = os.WriteFile(filepath.Join(s.Dir, name), body, 0755)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+func (s *LocalServer) serve(w http.ResponseWriter, name string) {
+	body, err := os.ReadFile(filepath.Join(s.Dir, name))
+	if os.IsNotExist(err) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.Write(body)
+}
@@ -0,0 +1,98 @@
+package viewer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/genshinsim/gcsimdb/pkg/db"
+	"github.com/genshinsim/gcsimdb/pkg/sim"
+)
+
+const resultJSON = `{
+	"config_file": "bennett char lvl=90/90;\noptions iteration=50;",
+	"dps": {"mean": 30000},
+	"char_details": [{"name": "bennett"}]
+}`
+
+func TestLocalServerRoundTrip(t *testing.T) {
+	srv, err := NewLocalServer(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	gz := filepath.Join(t.TempDir(), "bnfs")
+	err = sim.WriteGZ([]byte(resultJSON), gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []db.Pack{{
+		Path:    "db/Bennett/bnfs.yaml",
+		Author:  db.ParseAuthors("A#1234"),
+		DPS:     30000,
+		GzPath:  gz + ".gz",
+		Changed: true,
+	}}
+
+	c := NewClient(ts.URL)
+	err = c.UploadResults(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := data[0].ViewerKey
+	if key == "" || !data[0].Uploaded {
+		t.Fatalf("pack wasn't marked uploaded: %+v", data[0])
+	}
+
+	r, err := c.Fetch(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Config != sim.FixConfig("bennett char lvl=90/90;\noptions iteration=50;") {
+		t.Errorf("config = %q", r.Config)
+	}
+	if r.DPS.Mean != 30000 || len(r.Characters) != 1 || r.Characters[0].Name != "bennett" {
+		t.Errorf("result = %+v", r)
+	}
+
+	err = c.UploadIndex(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Get(ts.URL + "/db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET /db: %v", res.Status)
+	}
+	var index []db.Pack
+	err = json.NewDecoder(res.Body).Decode(&index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != 1 || index[0].ViewerKey != key || index[0].DPS != 30000 || index[0].Author.String() != "A#1234" {
+		t.Errorf("index = %+v", index)
+	}
+}
+
+func TestLocalServerUnknownKey(t *testing.T) {
+	srv, err := NewLocalServer(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	f := NewFetcher(ts.URL)
+	f.Retries = 0
+	_, err = f.Fetch("missing")
+	if err == nil {
+		t.Fatal("want an error for a key that was never uploaded")
+	}
+}
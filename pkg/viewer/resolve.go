@@ -0,0 +1,118 @@
+package viewer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Source is where a submitted result comes from: a result on the viewer or a
+// result file gcsim wrote locally
+type Source struct {
+	ID   string
+	File string
+}
+
+var reID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// share link paths on gcsim.app, e.g. /viewer/share/ID and /v3/viewer/share/ID
+var reSharePath = regexp.MustCompile(`^/(?:v\d+/)?viewer/share/([^/]+)/?$`)
+
+// hosts serving share links, and whether the id is the whole path
+var shareHosts = map[string]bool{
+	"gcsim.app":                false,
+	"www.gcsim.app":            false,
+	"viewer.gcsim.workers.dev": true,
+}
+
+// Resolve works out what input points at. It understands
+//   - share links, with or without scheme, query string, fragment or trailing
+//     slash: gcsim.app/viewer/share/ID, gcsim.app/v3/viewer/share/ID and
+//     viewer.gcsim.workers.dev/ID
+//   - perm_ links, whose prefix is part of the id on the viewer
+//   - bare viewer ids
+//   - paths to .json or .gz result files that aren't urls
+func Resolve(input string) (Source, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return Source{}, errors.New("empty link")
+	}
+
+	if isFile(input) {
+		return Source{File: input}, nil
+	}
+
+	if reID.MatchString(input) {
+		return Source{ID: input}, nil
+	}
+
+	raw := input
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Source{}, errors.Wrapf(err, "%q is not a share link", input)
+	}
+	whole, ok := shareHosts[strings.ToLower(u.Hostname())]
+	if !ok {
+		return Source{}, errors.Errorf("%q is not a gcsim share link", input)
+	}
+
+	var id string
+	if whole {
+		id = strings.Trim(u.Path, "/")
+	} else if m := reSharePath.FindStringSubmatch(u.Path); m != nil {
+		id = m[1]
+	}
+	if !reID.MatchString(id) {
+		return Source{}, errors.Errorf("no viewer id in %q", input)
+	}
+	return Source{ID: id}, nil
+}
+
+// isFile reports whether input is a .json or .gz result file: one that exists,
+// or a path that isn't a url
+func isFile(input string) bool {
+	lower := strings.ToLower(input)
+	if !strings.HasSuffix(lower, ".json") && !strings.HasSuffix(lower, ".gz") {
+		return false
+	}
+	if info, err := os.Stat(input); err == nil && !info.IsDir() {
+		return true
+	}
+	u, err := url.Parse(input)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
+// readFile loads a result gcsim wrote to disk, gzipped or not. A saved viewer
+// response works too.
+func readFile(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading result file")
+	}
+	if strings.EqualFold(filepath.Ext(path), ".gz") {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, errors.Wrap(err, "reading result file")
+		}
+		b, err = ioutil.ReadAll(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading result file")
+		}
+	}
+	var share shareData
+	if json.Unmarshal(b, &share) == nil && share.Data != "" {
+		return decodeShare(b)
+	}
+	return b, nil
+}
@@ -0,0 +1,72 @@
+package viewer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	cases := []struct {
+		input string
+		want  Source
+	}{
+		{"https://gcsim.app/viewer/share/abc123", Source{ID: "abc123"}},
+		{"http://gcsim.app/viewer/share/abc123", Source{ID: "abc123"}},
+		{"gcsim.app/viewer/share/abc123", Source{ID: "abc123"}},
+		{"https://www.gcsim.app/viewer/share/abc123", Source{ID: "abc123"}},
+		{"https://gcsim.app/v3/viewer/share/abc123", Source{ID: "abc123"}},
+		{"https://gcsim.app/viewer/share/abc123/", Source{ID: "abc123"}},
+		{"https://gcsim.app/viewer/share/abc123?tab=damage", Source{ID: "abc123"}},
+		{"https://gcsim.app/viewer/share/abc123#top", Source{ID: "abc123"}},
+		{"  https://gcsim.app/viewer/share/abc123  ", Source{ID: "abc123"}},
+		{"https://gcsim.app/viewer/share/perm_abc123", Source{ID: "perm_abc123"}},
+		{"https://viewer.gcsim.workers.dev/abc-123", Source{ID: "abc-123"}},
+		{"https://viewer.gcsim.workers.dev/perm_abc123", Source{ID: "perm_abc123"}},
+		{"abc_123-x", Source{ID: "abc_123-x"}},
+		{"results/team.json", Source{File: "results/team.json"}},
+		{"team.JSON", Source{File: "team.JSON"}},
+		{"/tmp/team.gz", Source{File: "/tmp/team.gz"}},
+	}
+	for _, c := range cases {
+		got, err := Resolve(c.input)
+		if err != nil {
+			t.Errorf("%q: %v", c.input, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%q: got %+v, want %+v", c.input, got, c.want)
+		}
+	}
+}
+
+func TestResolveRejects(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"   ",
+		"https://example.com/viewer/share/abc123",
+		"https://example.com/x.json",
+		"https://gcsim.app/x.gz",
+		"https://gcsim.app/viewer/abc123",
+		"https://gcsim.app/viewer/share/",
+		"https://gcsim.app/viewer/share/abc/def",
+		"https://viewer.gcsim.workers.dev/",
+		"not a link",
+	} {
+		if got, err := Resolve(input); err == nil {
+			t.Errorf("%q: got %+v, want an error", input, got)
+		}
+	}
+}
+
+func TestResolveExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "team.json")
+	err := os.WriteFile(path, []byte("{}"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Resolve(path)
+	if err != nil || got.File != path {
+		t.Errorf("got %+v, %v, want file %v", got, err, path)
+	}
+}
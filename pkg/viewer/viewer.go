@@ -0,0 +1,250 @@
+// Package viewer talks to the gcsim viewer, which hosts sim results and the db index.
+package viewer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/genshinsim/gcsimdb/pkg/db"
+	"github.com/genshinsim/gcsimdb/pkg/sim"
+	"github.com/joho/godotenv"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/pkg/errors"
+)
+
+// DefaultURL is the public viewer
+const DefaultURL = "https://viewer.gcsim.workers.dev"
+
+// Client talks to a viewer at BaseURL, either the public one or a LocalServer
+type Client struct {
+	BaseURL string
+	APIKey  string
+	//OnUpload is called after each result is uploaded, so progress can be
+	//recorded before moving on to the next one
+	OnUpload func(p db.Pack) error
+	//report what would be uploaded without sending anything; fetches still happen
+	DryRun bool
+	//reads share links
+	Fetcher *Fetcher
+}
+
+// NewClient returns a client for the viewer at baseURL. The API key is read
+// from the API_KEY env variable, which may be set in a .env file.
+func NewClient(baseURL string) *Client {
+	//a missing .env is fine as long as the key is set some other way
+	godotenv.Load()
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		APIKey:  os.Getenv("API_KEY"),
+		Fetcher: NewFetcher(baseURL),
+	}
+}
+
+type viewerData struct {
+	Data        string `json:"data"`
+	Author      string `json:"author"`
+	Description string `json:"description"`
+}
+
+type viewerRes struct {
+	ID string `json:"id"`
+}
+
+// UploadResults uploads the gzipped result of every changed pack and stores the
+// viewer key it gets back in the pack
+func (c *Client) UploadResults(data []db.Pack) error {
+	var err error
+	for i, v := range data {
+		//skip if no change and has a viewer key already
+		if !v.Changed && v.ViewerKey != "" {
+			continue
+		}
+		//nothing to upload if the sim never ran or failed
+		if v.GzPath == "" || v.Uploaded {
+			continue
+		}
+		if c.DryRun {
+			if v.ViewerKey == "" {
+				fmt.Printf("\t[dry-run] would upload results from %v under a new key\n", v.Path)
+			} else {
+				fmt.Printf("\t[dry-run] would upload results from %v, replacing %v\n", v.Path, v.ViewerKey)
+			}
+			continue
+		}
+
+		//check if key exists, if not generate one
+		key := v.ViewerKey
+
+		if key == "" {
+			key, err = gonanoid.New()
+			if err != nil {
+				return errors.Wrap(err, "")
+			}
+		}
+
+		//read the gz file
+		gzData, err := os.ReadFile(v.GzPath)
+		if err != nil {
+			return errors.Wrap(err, "reading gz data")
+		}
+		b64string := base64.StdEncoding.EncodeToString(gzData)
+
+		x := viewerData{
+			Data:        b64string,
+			Author:      v.Author.String(),
+			Description: "team database",
+		}
+
+		jsonData, err := json.Marshal(x)
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+
+		fmt.Printf("\tUploading results from %v to viewer: ", v.Path)
+
+		req, err := http.NewRequest("POST", c.BaseURL+"/key", bytes.NewBuffer(jsonData))
+		if err != nil {
+			fmt.Printf("FAILED, error: %v\n", err)
+			return errors.Wrap(err, "")
+		}
+		req.Header.Set("content-type", "application/json")
+		req.Header.Set("API-KEY", c.APIKey)
+		req.Header.Set("VIEWER_KEY", key)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			fmt.Printf("FAILED, error: %v\n", err)
+			return errors.Wrap(err, "")
+		}
+		if resp.StatusCode != 200 {
+			log.Println(resp.Status)
+			fmt.Printf("FAILED, error: %v\n", resp.Status)
+			return errors.Wrap(errors.New("http post request failed: "+resp.Status), "request failed")
+		}
+
+		//otherwise decode key from body
+		var res viewerRes
+		err = json.NewDecoder(resp.Body).Decode(&res)
+		if err != nil {
+			fmt.Printf("FAILED, error: %v\n", err)
+			return errors.Wrap(err, "")
+		}
+
+		data[i].ViewerKey = res.ID
+		data[i].Uploaded = true
+		data[i].RecordUpload()
+		fmt.Printf("OK, key = %v\n", res.ID)
+
+		if c.OnUpload != nil {
+			err = c.OnUpload(data[i])
+			if err != nil {
+				return errors.Wrap(err, "")
+			}
+		}
+	}
+	return nil
+}
+
+// UploadIndex replaces the db index on the viewer with data
+func (c *Client) UploadIndex(data []db.Pack) error {
+	if c.DryRun {
+		fmt.Printf("[dry-run] would upload DB index of %v teams to %v\n", len(data), c.BaseURL)
+		return nil
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	fmt.Print("Uploading DB index: ")
+
+	req, err := http.NewRequest("POST", c.BaseURL+"/db", bytes.NewBuffer(jsonData))
+	if err != nil {
+		fmt.Printf("FAILED, error: %v\n", err)
+		return errors.Wrap(err, "")
+	}
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("API-KEY", c.APIKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Printf("FAILED, error: %v\n", err)
+		return errors.Wrap(err, "")
+	}
+	if resp.StatusCode != 200 {
+		log.Println(resp.Status)
+		fmt.Printf("FAILED, error: %v\n", resp.Status)
+		return errors.Wrap(errors.New("http post request failed: "+resp.Status), "request failed")
+	}
+
+	fmt.Print("OK\n")
+
+	return nil
+}
+
+type shareData struct {
+	Data string `json:"data"`
+}
+
+// Fetch reads the result behind a viewer share link
+func (c *Client) Fetch(url string) (sim.Result, error) {
+	return c.Fetcher.Fetch(url)
+}
+
+// decodeShare unpacks the base64 zlib (or gzip) result the viewer hands out
+// into the result json
+func decodeShare(body []byte) ([]byte, error) {
+	var share shareData
+	err := json.Unmarshal(body, &share)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding viewer response")
+	}
+	if share.Data == "" {
+		return nil, errors.New("viewer response has no result data")
+	}
+	z, err := base64.StdEncoding.DecodeString(share.Data)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding result data")
+	}
+	var r io.Reader
+	r, err = zlib.NewReader(bytes.NewReader(z))
+	if err != nil {
+		r, err = gzip.NewReader(bytes.NewReader(z))
+		if err != nil {
+			return nil, errors.Wrap(err, "result data is neither zlib nor gzip")
+		}
+	}
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "decompressing result data")
+	}
+	return raw, nil
+}
+
+// parseResult reads result json and fixes its iterations
+func parseResult(raw []byte) (sim.Result, error) {
+	var data sim.Result
+	err := json.Unmarshal(raw, &data)
+	if err != nil {
+		return sim.Result{}, errors.Wrap(err, "decoding result")
+	}
+	if data.Config == "" || len(data.Characters) == 0 {
+		return sim.Result{}, errors.New("result has no config or characters")
+	}
+
+	//fix the iterations
+	data.Config = sim.FixConfig(data.Config)
+
+	return data, nil
+}